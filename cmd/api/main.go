@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github/ahmedghazey/packaging/internal/configuration"
 	"github/ahmedghazey/packaging/internal/http/handler"
+	"github/ahmedghazey/packaging/internal/repository"
 	"github/ahmedghazey/packaging/internal/server"
 	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/solver"
+	"github/ahmedghazey/packaging/internal/storage/file"
 	"github/ahmedghazey/packaging/internal/storage/inmemory"
+	"github/ahmedghazey/packaging/internal/storage/sqldb"
+	"github/ahmedghazey/packaging/internal/usecase"
 	"github/ahmedghazey/packaging/pkg/logging"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -23,15 +31,24 @@ func main() {
 	if err != nil {
 		log.Fatal("unable to initialize logger", err)
 	}
+	if _, err = solver.ParseObjective(config.PackingObjective); err != nil {
+		log.Fatal("invalid packing objective", err)
+	}
 	ctx := context.Background()
-	repository := inmemory.NewStorage()
+	repository, closeRepository, err := newRepository(config)
+	if err != nil {
+		log.Fatal("unable to open storage", err)
+	}
+	defer closeRepository()
 	packagingService := service.NewService(repository)
-	router := handler.Handler(packagingService)
+	allocations := usecase.NewAllocations(packagingService, config.AllocationTTL)
+	router := handler.Handler(packagingService, allocations, config)
 	httpServer := server.NewHttpServer(router)
 
 	go func() {
 		logging.Logger.WithContext(ctx).Info("starting server")
-		if err := httpServer.Run(); err != nil {
+		// Run returns http.ErrServerClosed once Stop is called
+		if err := httpServer.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logging.Logger.WithContext(ctx).Errorf("unable to start server", err)
 			os.Exit(1)
 		}
@@ -51,4 +68,34 @@ func main() {
 	if err != nil {
 		logging.Logger.WithContext(ctx).Errorf("unable to stop server gracefully", err)
 	}
+	// allocations are not stored, so their packs go back to stock before
+	// the storage closes
+	released, err := allocations.ReleaseAll()
+	if err != nil {
+		logging.Logger.WithContext(ctx).Errorf("unable to release allocations", err)
+	}
+	logging.Logger.WithContext(ctx).Infof("released %d reserved allocations", released)
+}
+
+// newRepository opens the storage selected by STORAGE and returns the function
+// releasing it on shutdown.
+func newRepository(config *configuration.AppConfiguration) (repository.PackageRepository, func() error, error) {
+	switch config.Storage {
+	case "", "inmemory":
+		return inmemory.NewStorage(), func() error { return nil }, nil
+	case "file":
+		storage, err := file.NewStorage(config.StorageDir, config.SnapshotEvery)
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage, storage.Close, nil
+	case sqldb.DriverSQLite, sqldb.DriverPostgres:
+		storage, err := sqldb.NewStorage(config.Storage, config.DatabaseDsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage, storage.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage %q", config.Storage)
+	}
 }
@@ -1,78 +1,99 @@
 package main
 
 import (
-	"cmp"
+	"context"
+	"flag"
 	"fmt"
-	"slices"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type solution struct {
-	currentCombination map[int]int
-}
-
-func (s solution) waste(order int) int {
-	sum := 0
-	for key, value := range s.currentCombination {
-		sum += key * value
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "recommend" {
+		recommend(os.Args[2:])
+		return
 	}
-	return sum - order
-}
 
-func (s solution) numberOfPackages() int {
-	sum := 0
-	for _, value := range s.currentCombination {
-		sum += value
+	sizes := flag.String("sizes", "5000,2000,1000,500,250", "comma separated pack sizes")
+	amount := flag.Int("amount", 251, "number of items to ship")
+	strategy := flag.String("strategy", solver.DefaultStrategy, "packing strategy: "+strings.Join(solver.Names(), ", "))
+	alternatives := flag.Int("alternatives", 0, "number of runner-up plans to print")
+	flag.Parse()
+
+	pkgs, err := getBestPackages(*sizes, *amount, *strategy, *alternatives)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, pkg := range pkgs {
+		fmt.Println(pkg)
 	}
-	return sum
 }
 
-func main() {
-
-	pkg := getBestPackage()
-	fmt.Print(pkg)
-}
+func getBestPackages(sizes string, amount int, strategy string, alternatives int) ([]domain.CandidatePackages, error) {
+	packingSolver, ok := solver.Get(strategy)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", strategy)
+	}
 
-func getBestPackage() solution {
-	packageSizes := []int{5000, 2000, 1000, 500, 250}
-	order := 251
-	result := optimizePackages(packageSizes, order)
-	slices.SortFunc(result, func(a, b solution) int {
-		if n := cmp.Compare(a.waste(order), b.waste(order)); n != 0 {
-			return n
+	packages := make([]*domain.Package, 0)
+	for _, field := range strings.Split(sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pack size %q: %w", field, err)
 		}
-		return cmp.Compare(a.numberOfPackages(), b.numberOfPackages())
-	})
+		packages = append(packages, &domain.Package{Size: size})
+	}
 
-	return result[0]
+	problem := solver.NewProblem(packages, amount)
+	problem.Alternatives = alternatives
+	return packingSolver.Solve(context.Background(), problem)
 }
 
-func optimizePackages(packageSizes []int, order int) []solution {
-	var result []solution
-	currentCombination := solution{make(map[int]int)}
-	memo := make(map[int]solution)
-	backtrack(packageSizes, order, currentCombination, &result, memo)
+// recommend searches for the pack sizes that best ship the orders of a CSV
+// file, one "amount,count" row per order.
+func recommend(args []string) {
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	orders := flags.String("orders", "", "CSV file of amount,count rows")
+	maxSizes := flags.Int("max-sizes", 3, "number of pack sizes to recommend")
+	minSize := flags.Int("min-size", 0, "smallest candidate size, 0 for no bound")
+	maxSize := flags.Int("max-size", 0, "largest candidate size, 0 for no bound")
+	step := flags.Int("step", 0, "candidate size step, 0 to use the ordered amounts")
+	timeout := flags.Duration("timeout", time.Minute, "search time limit, 0 for no limit")
+	flags.Parse(args)
 
-	return result
+	recommendation, err := getRecommendation(*orders, usecase.NewRecommendSizes(*maxSizes, *minSize, *maxSize, *step), *timeout)
+	if recommendation.Sizes != nil {
+		fmt.Println("sizes:", recommendation.Sizes)
+		fmt.Printf("%+v\n", recommendation.Totals)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
-func backtrack(packageSizes []int, order int, currentCombination solution, result *[]solution, memo map[int]solution) solution {
-	if order <= 0 {
-		*result = append(*result, currentCombination)
-		return currentCombination
+func getRecommendation(path string, recommend usecase.RecommendSizes, timeout time.Duration) (usecase.Recommendation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return usecase.Recommendation{}, err
 	}
-	if _, ok := memo[order]; ok {
-		return memo[order]
+	defer file.Close()
+	orders, err := usecase.ReadOrdersCsv(file)
+	if err != nil {
+		return usecase.Recommendation{}, fmt.Errorf("failed to read %s: %w", path, err)
 	}
-	for i := 0; i < len(packageSizes); i++ {
-		packageSize := packageSizes[i]
-		newCombination := solution{make(map[int]int)}
-		for k, v := range currentCombination.currentCombination {
-			newCombination.currentCombination[k] = v
-		}
-
-		newCombination.currentCombination[packageSize] += 1
 
-		memo[order] = backtrack(packageSizes, order-packageSize, newCombination, result, memo)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	return memo[order]
+	return recommend.Execute(ctx, orders, nil)
 }
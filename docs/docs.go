@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/add-packages": {
             "post": {
-                "description": "Add packages to the system",
+                "description": "Add packages to the system, all or none. Packages with a sku only serve orders for that product.",
                 "consumes": [
                     "application/json"
                 ],
@@ -47,23 +47,286 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or package size",
+                        "description": "Invalid request format or packages",
+                        "schema": {
+                            "$ref": "#/definitions/rest.BatchErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Sizes repeated in the request or already in the catalog",
+                        "schema": {
+                            "$ref": "#/definitions/rest.BatchErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/allocations": {
+            "post": {
+                "description": "Calculate a plan within the stock on hand, like /calculate-packages with useStock, and take its packs out of stock.\nThe allocation stays reserved until it is confirmed or released, or until ALLOCATION_TTL passes, when its\npacks return to stock.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Allocations"
+                ],
+                "summary": "Reserve stock for an order",
+                "parameters": [
+                    {
+                        "description": "Request body with the amount of items",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.CreateAllocationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Stock reserved",
+                        "schema": {
+                            "$ref": "#/definitions/rest.AllocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, options or amount",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "No package sizes configured, or the stock changed during the reservation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Amount cannot be fulfilled from stock within the waste limits",
+                        "schema": {
+                            "$ref": "#/definitions/rest.UnfulfillableResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Calculation cancelled or timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/allocations/{id}": {
+            "get": {
+                "description": "Get the plan and status (reserved, confirmed, released or expired) of an allocation.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Allocations"
+                ],
+                "summary": "Get an allocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Allocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The allocation",
+                        "schema": {
+                            "$ref": "#/definitions/rest.AllocationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Allocation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/allocations/{id}/confirm": {
+            "post": {
+                "description": "Keep the packs of a reserved allocation out of stock for good.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Allocations"
+                ],
+                "summary": "Confirm an allocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Allocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocation confirmed",
+                        "schema": {
+                            "$ref": "#/definitions/rest.AllocationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Allocation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Allocation no longer reserved, e.g. expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/allocations/{id}/release": {
+            "post": {
+                "description": "Return the packs of a reserved allocation to stock, e.g. when the order is abandoned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Allocations"
+                ],
+                "summary": "Release an allocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Allocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocation released",
+                        "schema": {
+                            "$ref": "#/definitions/rest.AllocationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Allocation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Allocation no longer reserved",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/calculate-order": {
+            "post": {
+                "description": "Calculate a plan for every line of the order with the pack sizes of the line's sku, plus the order totals.\nA sku may appear on a single line only. The calculation options apply to every line.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Calculate required packages for a multi-product order",
+                "parameters": [
+                    {
+                        "description": "Request body with the order lines and calculation options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.CalculateOrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Plans of every line and order totals",
+                        "schema": {
+                            "$ref": "#/definitions/rest.CalculateOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, options, amount or repeated sku",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "No package sizes configured for a sku",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "422": {
+                        "description": "A line cannot be fulfilled within the waste limits, or is too large",
+                        "schema": {
+                            "$ref": "#/definitions/rest.UnfulfillableResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "503": {
+                        "description": "Calculation cancelled or timed out",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
         "/calculate-packages": {
             "post": {
-                "description": "Calculate the minimum number of packages required for a given amount of items",
+                "description": "Calculate the minimum number of packages required for a given amount of items.\nThe optional sku selects the product whose pack sizes are used, the default catalog otherwise.\nThe optional strategy (exact, greedy, branch-and-bound, heuristic) overrides the configured one.\nWith useStock, no more packs of a size are planned than are in stock.\nThe objective overrides the configured ranking: a preset (minimize_waste, minimize_cost, minimize_packs),\ncriteria in priority order such as \"packs,waste\", or weights such as \"waste:1,packs:250\",\nover waste, packs, cost and distinct_sizes. Plans shipping more than maxWaste surplus items, or more than\nmaxWastePercent of the amount, are rejected, and exactOnly rejects any surplus. These only tighten the\nlimits configured for the catalog. When they leave no plan, the 422 response lists the closest plans.\nWith partial, the largest quantity not exceeding the amount, within stock when useStock is set, is shipped\nand the rest is reported as backordered.\nA capacity (maxItems, maxPacks, maxWeight per shipment, using the weights of the packs) splits the plan\ninto shipments; identical shipments are listed once with their count. Plans of up to 40 packs use the\nfewest shipments; larger ones fill each shipment in turn, largest packs first, which only guarantees the\nfewest when the pack sizes divide one another.\nWhen the search exceeds the configured compute budget a greedy plan is returned with optimal set to false.",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,6 +346,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/rest.CalculatePackagesRequest"
                         }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of runner-up plans to return, up to 20",
+                        "name": "alternatives",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Explain the choice: the runner-up plans beaten and on which criterion, and the plans ruled out by stock or maxWaste",
+                        "name": "explain",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -98,6 +373,70 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "409": {
+                        "description": "No package sizes configured",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Amount cannot be fulfilled within the waste limits, is too large, or a pack exceeds the capacity",
+                        "schema": {
+                            "$ref": "#/definitions/rest.UnfulfillableResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Calculation cancelled or timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/calculate-packages/batch": {
+            "post": {
+                "description": "Calculate the packages for every order of the batch against the same snapshot of the catalog.\nOrders that cannot be planned carry an error instead of packages; the other orders are unaffected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Calculate required packages for many orders",
+                "parameters": [
+                    {
+                        "description": "Request body with the orders and calculation options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.CalculatePackagesBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Per-order plans or errors, in request order",
+                        "schema": {
+                            "$ref": "#/definitions/rest.CalculatePackagesBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, options or number of orders",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -135,63 +474,1421 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "rest.AddPackagesRequest": {
-            "type": "object",
-            "properties": {
-                "packages": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/rest.Package"
-                    }
-                }
-            }
         },
-        "rest.AddPackagesResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
+        "/packages": {
+            "get": {
+                "description": "List the packages of every sku, or only those of the sku query parameter (empty for the default catalog),\nlargest size first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "List packages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product whose packages are listed",
+                        "name": "sku",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The packages",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.PackageResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace every package of every sku with the given ones, all or none. Packages keep the ID of the package\nof the same sku and size they replace. The catalog cannot be replaced while allocations are reserved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Replace the catalog",
+                "parameters": [
+                    {
+                        "description": "The new catalog",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.Package"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Catalog replaced",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.PackageResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or packages",
+                        "schema": {
+                            "$ref": "#/definitions/rest.BatchErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Sizes repeated for a sku, or allocations are reserved",
+                        "schema": {
+                            "$ref": "#/definitions/rest.BatchErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add one package size to the catalog of its sku, the default catalog without one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Create a package",
+                "parameters": [
+                    {
+                        "description": "The package",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.Package"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Package created",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PackageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or package",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "The sku already has a package of this size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/packages/analysis": {
+            "get": {
+                "description": "Report what the catalog can ship: only multiples of the gcd of the sizes can be shipped exactly, and frobenius\nis the largest amount that cannot, when the gcd is 1 (-1 when every amount can, null when the gcd is larger).\ndominatedSizes never appear in a best plan under the objective, the configured one unless overridden, and\naverageOvershoot is the mean of the least surplus shipped for each amount from \"from\" to \"to\".",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Analyse the pack sizes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Product whose pack sizes are analysed, the default catalog otherwise",
+                        "name": "sku",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "First amount of the overshoot range, 1 by default",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Last amount of the overshoot range, 10000 by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Objective deciding the dominated sizes",
+                        "name": "objective",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Analysis of the pack sizes",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PackagesAnalysisResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid range or objective",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "No package sizes configured",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Pack sizes too large to analyse",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Analysis cancelled or timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/packages/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Get a package",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Package ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The package",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PackageResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Package not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace every field of a package; fields left out are reset.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Replace a package",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Package ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The package",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.Package"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Package replaced",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PackageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or package",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Package not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "The sku already has a package of this size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Delete a package",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Package ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Package deleted"
+                    },
+                    "404": {
+                        "description": "Package not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change the given fields of a package in one write and keep the others, such as stock reserved meanwhile.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Update a package",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Package ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.PatchPackageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Package updated",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PackageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or package",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Package not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "The sku already has a package of this size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/recommendations": {
+            "post": {
+                "description": "Start searching, in the background, for the set of at most maxSizes pack sizes that ships the order\nhistory with the least overshoot, then the fewest packs. The candidates are the multiples of step from\nminSize to maxSize, or the ordered amounts within those bounds when no step is given. The search runs for\nat most RECOMMENDATION_TIMEOUT; poll the returned job for the best sizes found so far. Orders can also be\nuploaded as CSV (Content-Type text/csv) with one \"amount,count\" row per order, the search bounds then\nbeing given as query parameters.",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recommendations"
+                ],
+                "summary": "Recommend pack sizes",
+                "parameters": [
+                    {
+                        "description": "Request body with the orders and search bounds",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.RecommendSizesRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of sizes to recommend for CSV uploads",
+                        "name": "maxSizes",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Smallest candidate size for CSV uploads",
+                        "name": "minSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Largest candidate size for CSV uploads",
+                        "name": "maxSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Candidate size step for CSV uploads",
+                        "name": "step",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Recommendation started",
+                        "schema": {
+                            "$ref": "#/definitions/rest.RecommendationJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, orders or search bounds, or too many candidate sizes",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many recommendations running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/recommendations/{id}": {
+            "get": {
+                "description": "Get the status (running, done, stopped or failed) of a recommendation and the best sizes found so far.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recommendations"
+                ],
+                "summary": "Get a recommendation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recommendation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The recommendation",
+                        "schema": {
+                            "$ref": "#/definitions/rest.RecommendationJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Recommendation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/recommendations/{id}/cancel": {
+            "post": {
+                "description": "Stop a running recommendation, which keeps the best sizes found so far. Finished ones are left as they are.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recommendations"
+                ],
+                "summary": "Cancel a recommendation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recommendation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recommendation cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/rest.RecommendationJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Recommendation not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/simulations": {
+            "post": {
+                "description": "Plan historical orders with a baseline and a candidate set of pack sizes and compare the total overshoot,\npacks and cost of both, and the plans of every order. The live catalog is neither used for the candidate\nnor changed; the baseline defaults to the live pack sizes of the sku. Orders carry the number of times\nthe amount was placed, 1 by default. They can also be uploaded as CSV (Content-Type text/csv) with one\n\"amount,count\" row per order, the pack sizes then being given as comma-separated query parameters.\nWithout orders, the orders of the sku planned by the calculation endpoints since the service started are\nsimulated.",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packages"
+                ],
+                "summary": "Simulate a catalog change",
+                "parameters": [
+                    {
+                        "description": "Request body with the pack size sets, orders and calculation options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.SimulateCatalogRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Baseline pack sizes for CSV uploads, e.g. 250,500,1000",
+                        "name": "baseline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Candidate pack sizes for CSV uploads, e.g. 250,500,750",
+                        "name": "candidate",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Totals of both catalogs and per-order comparisons",
+                        "schema": {
+                            "$ref": "#/definitions/rest.SimulateCatalogResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format, pack sizes, options or orders",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Simulation cancelled or timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/plan-cache": {
+            "get": {
+                "description": "Hit, miss and eviction counters of the calculation result cache, for monitoring.\nThe cache is disabled when PLAN_CACHE_SIZE is 0.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Monitoring"
+                ],
+                "summary": "Plan cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "Cache counters",
+                        "schema": {
+                            "$ref": "#/definitions/rest.PlanCacheStatsResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "rest.AddPackagesRequest": {
+            "type": "object",
+            "properties": {
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.Package"
+                    }
+                }
+            }
+        },
+        "rest.AddPackagesResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.AllocationResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "cost": {
+                    "type": "number"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SizedPackage"
+                    }
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.BatchErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "rejected": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.RejectedPackageResponse"
+                    }
+                }
+            }
+        },
+        "rest.BatchOrder": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "orderId": {
+                    "type": "string"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.BatchOrderResult": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "optimal": {
+                    "type": "boolean"
+                },
+                "orderId": {
+                    "type": "string"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SizedPackage"
+                    }
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.CalculateOrderRequest": {
+            "type": "object",
+            "properties": {
+                "exactOnly": {
+                    "type": "boolean"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.OrderLine"
+                    }
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "maxWastePercent": {
+                    "type": "number"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "useStock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "rest.CalculateOrderResponse": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.LinePlan"
+                    }
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "optimal": {
+                    "type": "boolean"
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.CalculatePackagesBatchRequest": {
+            "type": "object",
+            "properties": {
+                "exactOnly": {
+                    "type": "boolean"
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "maxWastePercent": {
+                    "type": "number"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.BatchOrder"
+                    }
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "useStock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "rest.CalculatePackagesBatchResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.BatchOrderResult"
+                    }
+                }
+            }
+        },
+        "rest.CalculatePackagesRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "capacity": {
+                    "$ref": "#/definitions/rest.Capacity"
+                },
+                "exactOnly": {
+                    "type": "boolean"
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "maxWastePercent": {
+                    "type": "number"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "partial": {
+                    "type": "boolean"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "useStock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "rest.CalculatePackagesResponse": {
+            "type": "object",
+            "properties": {
+                "alternatives": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.PackingPlan"
+                    }
+                },
+                "backordered": {
+                    "type": "integer"
+                },
+                "cost": {
+                    "type": "number"
+                },
+                "explanation": {
+                    "$ref": "#/definitions/rest.Explanation"
+                },
+                "optimal": {
+                    "type": "boolean"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SizedPackage"
+                    }
+                },
+                "shipments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.Shipment"
+                    }
+                }
+            }
+        },
+        "rest.Capacity": {
+            "type": "object",
+            "properties": {
+                "maxItems": {
+                    "type": "integer"
+                },
+                "maxPacks": {
+                    "type": "integer"
+                },
+                "maxWeight": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.CreateAllocationRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "exactOnly": {
+                    "type": "boolean"
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "maxWastePercent": {
+                    "type": "number"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "useStock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "rest.EliminatedPlan": {
+            "type": "object",
+            "properties": {
+                "constraint": {
+                    "type": "string"
+                },
+                "plan": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                }
+            }
+        },
+        "rest.Explanation": {
+            "type": "object",
+            "properties": {
+                "eliminated": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.EliminatedPlan"
+                    }
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "runnersUp": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.RunnerUp"
+                    }
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "isAlive": {
+                    "type": "boolean"
+                },
+                "msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.LinePlan": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "cost": {
+                    "type": "number"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "optimal": {
+                    "type": "boolean"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SizedPackage"
+                    }
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "waste": {
+                    "type": "integer"
                 }
             }
         },
-        "rest.CalculatePackagesRequest": {
+        "rest.OrderComparison": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "baseline": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                },
+                "baselineError": {
+                    "type": "string"
+                },
+                "candidate": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                },
+                "candidateError": {
+                    "type": "string"
+                },
+                "costDelta": {
+                    "type": "number"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "packsDelta": {
+                    "type": "integer"
+                },
+                "wasteDelta": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.OrderLine": {
             "type": "object",
             "properties": {
                 "amount": {
                     "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
                 }
             }
         },
-        "rest.CalculatePackagesResponse": {
+        "rest.Package": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.PackageResponse": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.PackagesAnalysisResponse": {
+            "type": "object",
+            "properties": {
+                "averageOvershoot": {
+                    "type": "number"
+                },
+                "dominatedSizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "frobenius": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "gcd": {
+                    "type": "integer"
+                },
+                "sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.PackingPlan": {
             "type": "object",
             "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
                 "packages": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/rest.SizedPackage"
                     }
+                },
+                "waste": {
+                    "type": "integer"
                 }
             }
         },
-        "rest.HealthResponse": {
+        "rest.PatchPackageRequest": {
             "type": "object",
             "properties": {
-                "isAlive": {
+                "cost": {
+                    "type": "number"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.PlanCacheStatsResponse": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "enabled": {
                     "type": "boolean"
                 },
-                "msg": {
+                "evictions": {
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.RecommendSizesRequest": {
+            "type": "object",
+            "properties": {
+                "maxSize": {
+                    "type": "integer"
+                },
+                "maxSizes": {
+                    "type": "integer"
+                },
+                "minSize": {
+                    "type": "integer"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SimulatedOrder"
+                    }
+                },
+                "step": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.RecommendationJobResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "evaluations": {
+                    "type": "integer"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
+                },
+                "totals": {
+                    "$ref": "#/definitions/rest.SimulationTotals"
                 }
             }
         },
-        "rest.Package": {
+        "rest.RejectedPackageResponse": {
             "type": "object",
             "properties": {
+                "index": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
                 "size": {
                     "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.RunnerUp": {
+            "type": "object",
+            "properties": {
+                "decidedBy": {
+                    "type": "string"
+                },
+                "plan": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                }
+            }
+        },
+        "rest.Shipment": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "integer"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "packages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SizedPackage"
+                    }
+                },
+                "weight": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.SimulateCatalogRequest": {
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.Package"
+                    }
+                },
+                "candidate": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.Package"
+                    }
+                },
+                "exactOnly": {
+                    "type": "boolean"
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "maxWastePercent": {
+                    "type": "number"
+                },
+                "objective": {
+                    "type": "string"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.SimulatedOrder"
+                    }
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "useStock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "rest.SimulateCatalogResponse": {
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "$ref": "#/definitions/rest.SimulationTotals"
+                },
+                "candidate": {
+                    "$ref": "#/definitions/rest.SimulationTotals"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.OrderComparison"
+                    }
+                }
+            }
+        },
+        "rest.SimulatedOrder": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rest.SimulationTotals": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "numberOfPackages": {
+                    "type": "integer"
+                },
+                "orders": {
+                    "type": "integer"
+                },
+                "overshoot": {
+                    "type": "integer"
+                },
+                "unfulfilled": {
+                    "type": "integer"
                 }
             }
         },
@@ -205,6 +1902,26 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "rest.UnfulfillableResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "maxWaste": {
+                    "type": "integer"
+                },
+                "overFill": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                },
+                "underFill": {
+                    "$ref": "#/definitions/rest.PackingPlan"
+                }
+            }
         }
     }
 }`
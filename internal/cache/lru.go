@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Stats is a snapshot of a cache's counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+	Capacity  int
+}
+
+type entry[K comparable, V any] struct {
+	key    K
+	value  V
+	weight int
+}
+
+// LRU is a fixed capacity cache evicting the least recently used entries. It
+// is safe for concurrent use. Entries weigh one unless the cache weighs its
+// values, and Size and Capacity are total weights.
+type LRU[K comparable, V any] struct {
+	capacity int
+	weigh    func(V) int
+	weight   int
+	items    map[K]*list.Element
+	order    *list.List
+	stats    Stats
+	lock     sync.Mutex
+}
+
+// NewLRU creates a cache holding up to capacity entries.
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// NewWeightedLRU creates a cache holding entries up to capacity in total, as
+// weighed by weigh. Values heavier than the capacity are not cached.
+func NewWeightedLRU[K comparable, V any](capacity int, weigh func(V) int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		weigh:    weigh,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value cached for key and marks it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, found := c.items[key]
+	if !found {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*entry[K, V]).value, true
+}
+
+// Add caches value for key, evicting the least recently used entries when the
+// cache is full.
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	weight := 1
+	if c.weigh != nil {
+		weight = c.weigh(value)
+	}
+	if element, found := c.items[key]; found {
+		c.remove(element)
+	}
+	if weight > c.capacity {
+		return
+	}
+	for c.weight+weight > c.capacity {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, weight: weight})
+	c.weight += weight
+}
+
+func (c *LRU[K, V]) remove(element *list.Element) {
+	removed := c.order.Remove(element).(*entry[K, V])
+	delete(c.items, removed.key)
+	c.weight -= removed.weight
+}
+
+// Stats returns the cache's counters.
+func (c *LRU[K, V]) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Size = c.weight
+	stats.Capacity = c.capacity
+	return stats
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	lru := NewLRU[string, int](2)
+
+	_, found := lru.Get("a")
+	assert.False(t, found)
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	value, found := lru.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	// "b" is now the least recently used entry
+	lru.Add("c", 3)
+	_, found = lru.Get("b")
+	assert.False(t, found)
+	value, found = lru.Get("c")
+	assert.True(t, found)
+	assert.Equal(t, 3, value)
+
+	lru.Add("c", 4)
+	value, _ = lru.Get("c")
+	assert.Equal(t, 4, value)
+
+	assert.Equal(t, Stats{Hits: 3, Misses: 2, Evictions: 1, Size: 2, Capacity: 2}, lru.Stats())
+}
+
+func TestLRU_ZeroCapacity(t *testing.T) {
+	lru := NewLRU[string, int](0)
+
+	lru.Add("a", 1)
+	_, found := lru.Get("a")
+
+	assert.False(t, found)
+	assert.Equal(t, Stats{Misses: 1}, lru.Stats())
+}
+
+func TestLRU_Weighted(t *testing.T) {
+	lru := NewWeightedLRU[string, []int](5, func(value []int) int { return len(value) })
+
+	lru.Add("a", []int{1, 2})
+	lru.Add("b", []int{1, 2})
+	lru.Add("too heavy", []int{1, 2, 3, 4, 5, 6})
+	_, found := lru.Get("too heavy")
+	assert.False(t, found)
+
+	// "a" and "b" both go to make room
+	lru.Add("c", []int{1, 2, 3, 4})
+	_, found = lru.Get("a")
+	assert.False(t, found)
+	_, found = lru.Get("c")
+	assert.True(t, found)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 2, Evictions: 2, Size: 4, Capacity: 5}, lru.Stats())
+}
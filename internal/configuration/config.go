@@ -23,6 +23,24 @@ type AppConfiguration struct {
 	WaitingTimeout time.Duration `mapstructure:"WAITING_TIMEOUT"`
 	LogLevel       string        `mapstructure:"LOG_LEVEL"`
 	Environment    string        `mapstructure:"ENVIRONMENT"`
+
+	// Add packing configuration
+	PackingStrategy       string        `mapstructure:"PACKING_STRATEGY"`
+	PackingObjective      string        `mapstructure:"PACKING_OBJECTIVE"`
+	PlanCacheSize         int           `mapstructure:"PLAN_CACHE_SIZE"`
+	ComputeBudget         time.Duration `mapstructure:"COMPUTE_BUDGET"`
+	MaxWaste              int           `mapstructure:"MAX_WASTE"`
+	MaxWastePercent       float64       `mapstructure:"MAX_WASTE_PERCENT"`
+	ExactOnly             bool          `mapstructure:"EXACT_ONLY"`
+	AllocationTTL         time.Duration `mapstructure:"ALLOCATION_TTL"`
+	RecommendationTimeout time.Duration `mapstructure:"RECOMMENDATION_TIMEOUT"`
+	MaxRecommendations    int           `mapstructure:"MAX_RECOMMENDATIONS"`
+
+	// Add storage configuration
+	Storage       string `mapstructure:"STORAGE"`
+	StorageDir    string `mapstructure:"STORAGE_DIR"`
+	SnapshotEvery int    `mapstructure:"SNAPSHOT_EVERY"`
+	DatabaseDsn   string `mapstructure:"DATABASE_DSN"`
 }
 
 func loadConfig() (config AppConfiguration, err error) {
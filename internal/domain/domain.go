@@ -1,6 +1,114 @@
 package domain
 
+import (
+	"fmt"
+	"time"
+)
+
+// Package is a pack size in the catalog. Sku scopes the size to a product;
+// sizes without one form the default catalog. Stock is the number of packs of
+// that size on hand; it only limits calculations that ask for it. Cost is
+// what shipping one pack costs, packaging material and handling included,
+// and Weight what one pack weighs, counted against shipment capacities.
 type Package struct {
-	Id   string
-	Size int
+	Id     string
+	Sku    string
+	Size   int
+	Stock  int
+	Cost   float64
+	Weight float64
+}
+
+// PackagePatch lists the fields of a Package to change; nil fields are kept.
+type PackagePatch struct {
+	Sku    *string
+	Size   *int
+	Stock  *int
+	Cost   *float64
+	Weight *float64
+}
+
+// Apply changes the patch's fields of pkg.
+func (p PackagePatch) Apply(pkg *Package) {
+	if p.Sku != nil {
+		pkg.Sku = *p.Sku
+	}
+	if p.Size != nil {
+		pkg.Size = *p.Size
+	}
+	if p.Stock != nil {
+		pkg.Stock = *p.Stock
+	}
+	if p.Cost != nil {
+		pkg.Cost = *p.Cost
+	}
+	if p.Weight != nil {
+		pkg.Weight = *p.Weight
+	}
+}
+
+// ProductCatalog keeps the packages of the product identified by sku, in
+// their original order. An empty sku selects the default catalog.
+func ProductCatalog(packages []*Package, sku string) []*Package {
+	catalog := make([]*Package, 0, len(packages))
+	for _, pkg := range packages {
+		if pkg.Sku == sku {
+			catalog = append(catalog, pkg)
+		}
+	}
+	return catalog
+}
+
+// CheckBatch rejects the packages of batch whose sku already has their size,
+// in catalog or earlier in the batch. It returns a *BatchError listing them,
+// or nil when the whole batch can be written.
+func CheckBatch(catalog, batch []*Package) error {
+	type key struct {
+		sku  string
+		size int
+	}
+	taken := make(map[key]int, len(catalog)+len(batch))
+	for _, pkg := range catalog {
+		taken[key{pkg.Sku, pkg.Size}] = -1
+	}
+	var rejected []RejectedPackage
+	for i, pkg := range batch {
+		index, exists := taken[key{pkg.Sku, pkg.Size}]
+		switch {
+		case !exists:
+			taken[key{pkg.Sku, pkg.Size}] = i
+		case index < 0:
+			rejected = append(rejected, RejectedPackage{Index: i, Err: DuplicateSizeError(pkg)})
+		default:
+			rejected = append(rejected, RejectedPackage{Index: i, Err: fmt.Errorf("%w at index %d", DuplicateSizeError(pkg), index)})
+		}
+	}
+	if len(rejected) > 0 {
+		return &BatchError{Rejected: rejected}
+	}
+	return nil
+}
+
+// AllocationStatus is the stage of an Allocation.
+type AllocationStatus string
+
+const (
+	AllocationReserved  AllocationStatus = "reserved"
+	AllocationConfirmed AllocationStatus = "confirmed"
+	AllocationReleased  AllocationStatus = "released"
+	AllocationExpired   AllocationStatus = "expired"
+)
+
+// Allocation holds the packs of an order's plan out of stock. Packs maps the
+// IDs of the catalog packages to the packs taken from them. A reserved
+// allocation returns its packs to stock when it is released or reaches
+// ExpiresAt, unless it is confirmed first; a zero ExpiresAt never expires.
+type Allocation struct {
+	Id        string
+	Sku       string
+	Amount    int
+	Plan      PackingPlan
+	Packs     map[string]int
+	Status    AllocationStatus
+	ExpiresAt time.Time
 }
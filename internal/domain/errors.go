@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidAmount is returned for orders without a positive amount.
+	ErrInvalidAmount = errors.New("amount must be a positive integer greater than 0")
+	// ErrDuplicateOrderLine is returned for orders listing a product twice.
+	ErrDuplicateOrderLine = errors.New("order has more than one line for the same sku")
+	// ErrNoPackageSizes is returned when a calculation is requested before
+	// any package size has been added.
+	ErrNoPackageSizes = errors.New("no package sizes configured")
+	// ErrAmountUnreachable is returned when no combination of the available
+	// packs satisfies the order under the requested constraints.
+	ErrAmountUnreachable = errors.New("amount cannot be fulfilled with the available packages")
+	// ErrAmountTooLarge is returned when the amount exceeds what the chosen
+	// strategy can compute within its memory limits.
+	ErrAmountTooLarge = errors.New("amount is too large for the selected strategy")
+	// ErrCatalogTooLarge is returned when the pack sizes are too large to
+	// analyse within the memory limits.
+	ErrCatalogTooLarge = errors.New("pack sizes are too large to analyse")
+	// ErrPackExceedsCapacity is returned when a single pack of a plan does not
+	// fit in a shipment.
+	ErrPackExceedsCapacity = errors.New("pack exceeds the shipment capacity")
+	// ErrTooManyCandidateSizes is returned when a recommendation would have
+	// to evaluate more candidate sizes than it is allowed to.
+	ErrTooManyCandidateSizes = errors.New("too many candidate pack sizes")
+	// ErrRecommendationNotFound is returned for unknown recommendation job IDs.
+	ErrRecommendationNotFound = errors.New("recommendation not found")
+	// ErrTooManyRecommendations is returned when starting a recommendation
+	// while the most allowed at once are running.
+	ErrTooManyRecommendations = errors.New("too many recommendations running")
+	// ErrPackageNotFound is returned for unknown package IDs.
+	ErrPackageNotFound = errors.New("package not found")
+	// ErrDuplicatePackageSize is returned when a package would share its size
+	// with another package of the same sku.
+	ErrDuplicatePackageSize = errors.New("package size already exists")
+	// ErrInsufficientStock is returned when reserving more packs than are in
+	// stock.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrAllocationNotFound is returned for unknown allocation IDs.
+	ErrAllocationNotFound = errors.New("allocation not found")
+	// ErrAllocationClosed is returned when confirming or releasing an
+	// allocation that is no longer reserved.
+	ErrAllocationClosed = errors.New("allocation is no longer reserved")
+	// ErrAllocationsReserved is returned when replacing the catalog while
+	// allocations hold packs that would be returned on top of the new stock.
+	ErrAllocationsReserved = errors.New("allocations are reserved")
+)
+
+// UnfulfillableError is returned when plans covering Amount exist but none
+// ships at most MaxWaste surplus items. It lists the closest plans on either
+// side of the amount: Under ships the most items without covering the order,
+// its Waste being the negated shortfall, and is nil when no pack fits; Over
+// ships the fewest surplus items. It unwraps to ErrAmountUnreachable.
+type UnfulfillableError struct {
+	Amount   int
+	MaxWaste int
+	Under    *PackingPlan
+	Over     PackingPlan
+}
+
+func (e *UnfulfillableError) Error() string {
+	return fmt.Sprintf("%v: no plan ships %d items with at most %d surplus items, the closest ships %d",
+		ErrAmountUnreachable, e.Amount, e.MaxWaste, e.Amount+e.Over.Waste)
+}
+
+func (e *UnfulfillableError) Unwrap() error {
+	return ErrAmountUnreachable
+}
+
+// DuplicateSizeError describes a package whose size its sku already has. It
+// unwraps to ErrDuplicatePackageSize.
+func DuplicateSizeError(item *Package) error {
+	if item.Sku != "" {
+		return fmt.Errorf("%w: size %d for sku %q", ErrDuplicatePackageSize, item.Size, item.Sku)
+	}
+	return fmt.Errorf("%w: size %d", ErrDuplicatePackageSize, item.Size)
+}
+
+// RejectedPackage is a package of a batch, identified by its Index in the
+// batch, and the reason it was rejected.
+type RejectedPackage struct {
+	Index int
+	Err   error
+}
+
+// BatchError is returned when a batch of packages written all or none is
+// rejected, so none of them was written. It lists every rejected package and
+// unwraps to their errors.
+type BatchError struct {
+	Rejected []RejectedPackage
+}
+
+func (e *BatchError) Error() string {
+	reasons := make([]string, 0, len(e.Rejected))
+	for _, rejected := range e.Rejected {
+		reasons = append(reasons, fmt.Sprintf("index %d: %v", rejected.Index, rejected.Err))
+	}
+	return "packages rejected: " + strings.Join(reasons, "; ")
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Rejected))
+	for _, rejected := range e.Rejected {
+		errs = append(errs, rejected.Err)
+	}
+	return errs
+}
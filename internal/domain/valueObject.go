@@ -1,10 +1,49 @@
 package domain
 
+import (
+	"cmp"
+	"slices"
+)
+
 type SizedPackage struct {
 	Quantity int
 	Size     int
 }
 
+// PackingPlan is a candidate turned into the packs to ship, largest first,
+// with its overshoot and pack count for the order it was computed for and
+// its total cost.
+type PackingPlan struct {
+	Packages         []*SizedPackage
+	Waste            int
+	NumberOfPackages int
+	Cost             float64
+}
+
+// Capacity is what a carrier accepts in one shipment: at most MaxItems items,
+// MaxPacks packs and MaxWeight weight. Limits that are not positive do not
+// apply.
+type Capacity struct {
+	MaxItems  int
+	MaxPacks  int
+	MaxWeight float64
+}
+
+// Unlimited reports whether no limit applies.
+func (c Capacity) Unlimited() bool {
+	return c.MaxItems <= 0 && c.MaxPacks <= 0 && c.MaxWeight <= 0
+}
+
+// Shipment is a parcel of packs, largest first, within a carrier's capacity.
+// Count is how many identical parcels are shipped.
+type Shipment struct {
+	Packages         []*SizedPackage
+	Count            int
+	Items            int
+	NumberOfPackages int
+	Weight           float64
+}
+
 type CandidatePackages struct {
 	CurrentCombination map[int]int
 }
@@ -24,3 +63,41 @@ func (s CandidatePackages) NumberOfPackages() int {
 	}
 	return sum
 }
+
+// Cost totals the unit cost of every pack in the candidate.
+func (s CandidatePackages) Cost(costs map[int]float64) float64 {
+	sum := 0.0
+	for key, value := range s.CurrentCombination {
+		sum += costs[key] * float64(value)
+	}
+	return sum
+}
+
+// Compare ranks two candidates for the same order: the one shipping fewer
+// surplus items wins (Rule 2), then the one using fewer packs (Rule 3).
+func (s CandidatePackages) Compare(other CandidatePackages, order int) int {
+	if n := cmp.Compare(s.Waste(order), other.Waste(order)); n != 0 {
+		return n
+	}
+	return cmp.Compare(s.NumberOfPackages(), other.NumberOfPackages())
+}
+
+// Plan lists the candidate's packs for order, largest size first, priced
+// with costs.
+func (s CandidatePackages) Plan(order int, costs map[int]float64) PackingPlan {
+	packages := make([]*SizedPackage, 0, len(s.CurrentCombination))
+	for size, quantity := range s.CurrentCombination {
+		if quantity > 0 {
+			packages = append(packages, &SizedPackage{Size: size, Quantity: quantity})
+		}
+	}
+	slices.SortFunc(packages, func(a, b *SizedPackage) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+	return PackingPlan{
+		Packages:         packages,
+		Waste:            s.Waste(order),
+		NumberOfPackages: s.NumberOfPackages(),
+		Cost:             s.Cost(costs),
+	}
+}
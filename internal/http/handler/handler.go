@@ -2,17 +2,63 @@ package handler
 
 import (
 	"github.com/go-chi/chi/v5"
+	"github/ahmedghazey/packaging/internal/configuration"
 	"github/ahmedghazey/packaging/internal/http/rest"
 	"github/ahmedghazey/packaging/internal/middleware"
 	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/solver"
+	"github/ahmedghazey/packaging/internal/usecase"
 	"net/http"
 )
 
-func Handler(packagingService service.PackageService) http.Handler {
+func Handler(packagingService service.PackageService, allocations *usecase.Allocations, config *configuration.AppConfiguration) http.Handler {
+	// the objective definition is validated when the application starts
+	objective, _ := solver.ParseObjective(config.PackingObjective)
+	calculateDefaults := usecase.CalculateOptions{
+		Strategy:  config.PackingStrategy,
+		Objective: objective,
+		Budget:    config.ComputeBudget,
+		// the catalog-wide waste limits, which requests can only tighten
+		MaxWaste:        config.MaxWaste,
+		MaxWastePercent: config.MaxWastePercent,
+		ExactOnly:       config.ExactOnly,
+	}
+	if config.PlanCacheSize > 0 {
+		calculateDefaults.Cache = usecase.NewPlanCache(config.PlanCacheSize)
+	}
+
+	history := usecase.NewOrderHistory()
+	recommendations := usecase.NewRecommendationJobs(config.RecommendationTimeout)
+	if config.MaxRecommendations > 0 {
+		recommendations.MaxRunning = config.MaxRecommendations
+	}
+
 	router := chi.NewRouter()
 	router.Use(middleware.Recovery)
+	if config.WriteTimeout > 0 {
+		router.Use(middleware.Timeout(config.WriteTimeout))
+	}
 	router.Get("/health", rest.Health())
+	router.Get("/stats/plan-cache", rest.PlanCacheStats(calculateDefaults.Cache))
 	router.Post("/add-packages", rest.AddPackages(packagingService))
-	router.Post("/calculate-packages", rest.CalculatePackages(packagingService))
+	router.Get("/packages", rest.ListPackages(packagingService))
+	router.Post("/packages", rest.CreatePackage(packagingService))
+	router.Put("/packages", rest.ReplacePackages(allocations))
+	router.Get("/packages/analysis", rest.AnalyzePackages(packagingService, calculateDefaults))
+	router.Get("/packages/{id}", rest.GetPackage(packagingService))
+	router.Put("/packages/{id}", rest.ReplacePackage(packagingService))
+	router.Patch("/packages/{id}", rest.PatchPackage(packagingService))
+	router.Delete("/packages/{id}", rest.DeletePackage(packagingService))
+	router.Post("/calculate-packages", rest.CalculatePackages(packagingService, calculateDefaults, history))
+	router.Post("/calculate-packages/batch", rest.CalculatePackagesBatch(packagingService, calculateDefaults, history))
+	router.Post("/calculate-order", rest.CalculateOrder(packagingService, calculateDefaults, history))
+	router.Post("/simulations", rest.SimulateCatalog(packagingService, calculateDefaults, history))
+	router.Post("/allocations", rest.CreateAllocation(allocations, calculateDefaults))
+	router.Get("/allocations/{id}", rest.GetAllocation(allocations))
+	router.Post("/allocations/{id}/confirm", rest.ConfirmAllocation(allocations))
+	router.Post("/allocations/{id}/release", rest.ReleaseAllocation(allocations))
+	router.Post("/recommendations", rest.RecommendSizes(recommendations))
+	router.Get("/recommendations/{id}", rest.GetRecommendation(recommendations))
+	router.Post("/recommendations/{id}/cancel", rest.CancelRecommendation(recommendations))
 	return router
 }
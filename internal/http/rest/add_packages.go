@@ -2,6 +2,7 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"github/ahmedghazey/packaging/internal/domain"
 	"github/ahmedghazey/packaging/internal/service"
 	"github/ahmedghazey/packaging/internal/usecase"
@@ -9,7 +10,11 @@ import (
 )
 
 type Package struct {
-	Size int `json:"size"`
+	Sku    string  `json:"sku,omitempty"`
+	Size   int     `json:"size"`
+	Stock  int     `json:"stock,omitempty"`
+	Cost   float64 `json:"cost,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
 }
 type AddPackagesRequest struct {
 	Packages []Package `json:"packages"`
@@ -18,15 +23,32 @@ type AddPackagesResponse struct {
 	Message string `json:"message"`
 }
 
+// RejectedPackageResponse is a package of the request, identified by its
+// index, and why it was rejected.
+type RejectedPackageResponse struct {
+	Index  int    `json:"index"`
+	Sku    string `json:"sku,omitempty"`
+	Size   int    `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// BatchErrorResponse lists every package of a rejected batch, none of which
+// was written.
+type BatchErrorResponse struct {
+	Error    string                    `json:"error"`
+	Rejected []RejectedPackageResponse `json:"rejected"`
+}
+
 // AddPackages
 // @Summary Add packages
-// @Description Add packages to the system
+// @Description Add packages to the system, all or none. Packages with a sku only serve orders for that product.
 // @Tags Packages
 // @Accept json
 // @Produce json
 // @Param request body AddPackagesRequest true "Request body with packages to add"
 // @Success 200 {object} AddPackagesResponse "Packages added successfully"
-// @Failure 400 {object} string "Invalid request format or package size"
+// @Failure 400 {object} BatchErrorResponse "Invalid request format or packages"
+// @Failure 409 {object} BatchErrorResponse "Sizes repeated in the request or already in the catalog"
 // @Failure 500 {object} string "Internal server error"
 // @Router /add-packages [post]
 func AddPackages(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
@@ -37,18 +59,15 @@ func AddPackages(packagingService service.PackageService) func(w http.ResponseWr
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		addPackagesUsecase := usecase.NewAddPackages(packagingService)
-		packages := make([]*domain.Package, 0, len(addPackageRequest.Packages))
-		for _, pkg := range addPackageRequest.Packages {
-			if pkg.Size <= 0 {
-				http.Error(w, "Package size must be a positive integer greater than 0", http.StatusBadRequest)
-				return
-			}
-			packages = append(packages, &domain.Package{Size: pkg.Size})
+		packages, err := toDomainPackages(addPackageRequest.Packages)
+		if err != nil {
+			writePackagesError(w, http.StatusBadRequest, addPackageRequest.Packages, err)
+			return
 		}
+		addPackagesUsecase := usecase.NewAddPackages(packagingService)
 		err = addPackagesUsecase.Execute(packages)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writePackagesError(w, packageErrorStatus(err), addPackageRequest.Packages, err)
 			return
 		}
 
@@ -61,3 +80,66 @@ func AddPackages(packagingService service.PackageService) func(w http.ResponseWr
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func (p Package) validate() error {
+	switch {
+	case p.Size <= 0:
+		return errors.New("Package size must be a positive integer greater than 0")
+	case p.Stock < 0:
+		return errors.New("Package stock must not be negative")
+	case p.Cost < 0:
+		return errors.New("Package cost must not be negative")
+	case p.Weight < 0:
+		return errors.New("Package weight must not be negative")
+	}
+	return nil
+}
+
+func (p Package) toDomain() *domain.Package {
+	return &domain.Package{Sku: p.Sku, Size: p.Size, Stock: p.Stock, Cost: p.Cost, Weight: p.Weight}
+}
+
+// toDomainPackages validates every package, failing with a *domain.BatchError
+// listing the invalid ones.
+func toDomainPackages(packages []Package) ([]*domain.Package, error) {
+	items := make([]*domain.Package, 0, len(packages))
+	var rejected []domain.RejectedPackage
+	for i, pkg := range packages {
+		if err := pkg.validate(); err != nil {
+			rejected = append(rejected, domain.RejectedPackage{Index: i, Err: err})
+			continue
+		}
+		items = append(items, pkg.toDomain())
+	}
+	if len(rejected) > 0 {
+		return nil, &domain.BatchError{Rejected: rejected}
+	}
+	return items, nil
+}
+
+// writePackagesError answers with every rejected package of the request when
+// its batch was rejected, and with the error's text otherwise.
+func writePackagesError(w http.ResponseWriter, status int, packages []Package, err error) {
+	var batchErr *domain.BatchError
+	if !errors.As(err, &batchErr) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	response := BatchErrorResponse{
+		Error:    err.Error(),
+		Rejected: make([]RejectedPackageResponse, 0, len(batchErr.Rejected)),
+	}
+	for _, rejected := range batchErr.Rejected {
+		pkg := packages[rejected.Index]
+		response.Rejected = append(response.Rejected, RejectedPackageResponse{
+			Index:  rejected.Index,
+			Sku:    pkg.Sku,
+			Size:   pkg.Size,
+			Reason: rejected.Err.Error(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
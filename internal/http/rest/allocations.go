@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+	"time"
+)
+
+type CreateAllocationRequest struct {
+	Sku    string `json:"sku,omitempty"`
+	Amount int    `json:"amount"`
+	CalculationOptions
+}
+type AllocationResponse struct {
+	Id               string          `json:"id"`
+	Sku              string          `json:"sku,omitempty"`
+	Amount           int             `json:"amount"`
+	Packages         []*SizedPackage `json:"packages"`
+	Waste            int             `json:"waste"`
+	NumberOfPackages int             `json:"numberOfPackages"`
+	Cost             float64         `json:"cost"`
+	Status           string          `json:"status"`
+	ExpiresAt        *time.Time      `json:"expiresAt,omitempty"`
+}
+
+// CreateAllocation
+// @Summary Reserve stock for an order
+// @Description Calculate a plan within the stock on hand, like /calculate-packages with useStock, and take its packs out of stock.
+// @Description The allocation stays reserved until it is confirmed or released, or until ALLOCATION_TTL passes, when its
+// @Description packs return to stock.
+// @Tags Allocations
+// @Accept json
+// @Produce json
+// @Param request body CreateAllocationRequest true "Request body with the amount of items"
+// @Success 201 {object} AllocationResponse "Stock reserved"
+// @Failure 400 {object} string "Invalid request format, options or amount"
+// @Failure 409 {object} string "No package sizes configured, or the stock changed during the reservation"
+// @Failure 422 {object} UnfulfillableResponse "Amount cannot be fulfilled from stock within the waste limits"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Calculation cancelled or timed out"
+// @Router /allocations [post]
+func CreateAllocation(allocations *usecase.Allocations, defaults usecase.CalculateOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var createAllocationRequest CreateAllocationRequest
+		err := json.NewDecoder(r.Body).Decode(&createAllocationRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if createAllocationRequest.Amount <= 0 {
+			http.Error(w, "Amount must be a positive integer greater than 0", http.StatusBadRequest)
+			return
+		}
+		options, err := createAllocationRequest.CalculationOptions.apply(defaults)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		allocation, err := allocations.Reserve(r.Context(), options, createAllocationRequest.Sku, createAllocationRequest.Amount)
+		if err != nil {
+			writeCalculationError(w, err)
+			return
+		}
+		writeAllocation(w, http.StatusCreated, allocation)
+	}
+}
+
+// GetAllocation
+// @Summary Get an allocation
+// @Description Get the plan and status (reserved, confirmed, released or expired) of an allocation.
+// @Tags Allocations
+// @Produce json
+// @Param id path string true "Allocation ID"
+// @Success 200 {object} AllocationResponse "The allocation"
+// @Failure 404 {object} string "Allocation not found"
+// @Router /allocations/{id} [get]
+func GetAllocation(allocations *usecase.Allocations) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allocation, err := allocations.Get(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), allocationErrorStatus(err))
+			return
+		}
+		writeAllocation(w, http.StatusOK, allocation)
+	}
+}
+
+// ConfirmAllocation
+// @Summary Confirm an allocation
+// @Description Keep the packs of a reserved allocation out of stock for good.
+// @Tags Allocations
+// @Produce json
+// @Param id path string true "Allocation ID"
+// @Success 200 {object} AllocationResponse "Allocation confirmed"
+// @Failure 404 {object} string "Allocation not found"
+// @Failure 409 {object} string "Allocation no longer reserved, e.g. expired"
+// @Failure 500 {object} string "Internal server error"
+// @Router /allocations/{id}/confirm [post]
+func ConfirmAllocation(allocations *usecase.Allocations) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allocation, err := allocations.Confirm(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), allocationErrorStatus(err))
+			return
+		}
+		writeAllocation(w, http.StatusOK, allocation)
+	}
+}
+
+// ReleaseAllocation
+// @Summary Release an allocation
+// @Description Return the packs of a reserved allocation to stock, e.g. when the order is abandoned.
+// @Tags Allocations
+// @Produce json
+// @Param id path string true "Allocation ID"
+// @Success 200 {object} AllocationResponse "Allocation released"
+// @Failure 404 {object} string "Allocation not found"
+// @Failure 409 {object} string "Allocation no longer reserved"
+// @Failure 500 {object} string "Internal server error"
+// @Router /allocations/{id}/release [post]
+func ReleaseAllocation(allocations *usecase.Allocations) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allocation, err := allocations.Release(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), allocationErrorStatus(err))
+			return
+		}
+		writeAllocation(w, http.StatusOK, allocation)
+	}
+}
+
+func writeAllocation(w http.ResponseWriter, status int, allocation domain.Allocation) {
+	response := AllocationResponse{
+		Id:               allocation.Id,
+		Sku:              allocation.Sku,
+		Amount:           allocation.Amount,
+		Packages:         toSizedPackages(allocation.Plan.Packages),
+		Waste:            allocation.Plan.Waste,
+		NumberOfPackages: allocation.Plan.NumberOfPackages,
+		Cost:             allocation.Plan.Cost,
+		Status:           string(allocation.Status),
+	}
+	if !allocation.ExpiresAt.IsZero() {
+		response.ExpiresAt = &allocation.ExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// allocationErrorStatus maps allocation failures to HTTP status codes.
+func allocationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrAllocationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrAllocationClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
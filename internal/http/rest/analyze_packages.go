@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/solver"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultAnalysisTo is the last amount the overshoot is averaged over when
+// the request does not name one.
+const defaultAnalysisTo = 10000
+
+type PackagesAnalysisResponse struct {
+	Sizes            []int   `json:"sizes"`
+	Gcd              int     `json:"gcd"`
+	Frobenius        *int    `json:"frobenius"`
+	DominatedSizes   []int   `json:"dominatedSizes"`
+	From             int     `json:"from"`
+	To               int     `json:"to"`
+	AverageOvershoot float64 `json:"averageOvershoot"`
+}
+
+// AnalyzePackages
+// @Summary Analyse the pack sizes
+// @Description Report what the catalog can ship: only multiples of the gcd of the sizes can be shipped exactly, and frobenius
+// @Description is the largest amount that cannot, when the gcd is 1 (-1 when every amount can, null when the gcd is larger).
+// @Description dominatedSizes never appear in a best plan under the objective, the configured one unless overridden, and
+// @Description averageOvershoot is the mean of the least surplus shipped for each amount from "from" to "to".
+// @Tags Packages
+// @Produce json
+// @Param sku query string false "Product whose pack sizes are analysed, the default catalog otherwise"
+// @Param from query int false "First amount of the overshoot range, 1 by default"
+// @Param to query int false "Last amount of the overshoot range, 10000 by default"
+// @Param objective query string false "Objective deciding the dominated sizes"
+// @Success 200 {object} PackagesAnalysisResponse "Analysis of the pack sizes"
+// @Failure 400 {object} string "Invalid range or objective"
+// @Failure 409 {object} string "No package sizes configured"
+// @Failure 422 {object} string "Pack sizes too large to analyse"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Analysis cancelled or timed out"
+// @Router /packages/analysis [get]
+func AnalyzePackages(packagingService service.PackageService, defaults usecase.CalculateOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, to := 1, defaultAnalysisTo
+		var err error
+		if value := query.Get("from"); value != "" {
+			if from, err = strconv.Atoi(value); err != nil || from <= 0 {
+				http.Error(w, "From must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := query.Get("to"); value != "" {
+			if to, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "To must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+		if to < from {
+			http.Error(w, "To must not be smaller than from", http.StatusBadRequest)
+			return
+		}
+		objective := defaults.Objective
+		if value := query.Get("objective"); value != "" {
+			if objective, err = solver.ParseObjective(value); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid objective: %s, expected a preset (%s), ordered criteria or weighted criteria",
+					err, strings.Join(solver.PresetNames(), ", ")), http.StatusBadRequest)
+				return
+			}
+		}
+
+		analyzePackagesUsecase := usecase.NewAnalyzePackages(packagingService, objective)
+		analysis, err := analyzePackagesUsecase.Execute(r.Context(), query.Get("sku"), from, to)
+		if err != nil {
+			http.Error(w, err.Error(), calculationErrorStatus(err))
+			return
+		}
+		response := PackagesAnalysisResponse{
+			Sizes:            analysis.Sizes,
+			Gcd:              analysis.Gcd,
+			Frobenius:        analysis.Frobenius,
+			DominatedSizes:   analysis.DominatedSizes,
+			From:             analysis.From,
+			To:               analysis.To,
+			AverageOvershoot: analysis.AverageOvershoot,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
+	}
+}
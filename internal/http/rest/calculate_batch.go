@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+)
+
+// maxBatchOrders bounds the orders a single batch request may carry.
+const maxBatchOrders = 10000
+
+type BatchOrder struct {
+	OrderId string `json:"orderId"`
+	Sku     string `json:"sku,omitempty"`
+	Amount  int    `json:"amount"`
+}
+type CalculatePackagesBatchRequest struct {
+	Orders []BatchOrder `json:"orders"`
+	CalculationOptions
+}
+type BatchOrderResult struct {
+	OrderId          string          `json:"orderId"`
+	Packages         []*SizedPackage `json:"packages,omitempty"`
+	Waste            int             `json:"waste"`
+	NumberOfPackages int             `json:"numberOfPackages"`
+	Cost             float64         `json:"cost"`
+	Optimal          bool            `json:"optimal"`
+	Error            string          `json:"error,omitempty"`
+}
+type CalculatePackagesBatchResponse struct {
+	Results []*BatchOrderResult `json:"results"`
+}
+
+// CalculatePackagesBatch
+// @Summary Calculate required packages for many orders
+// @Description Calculate the packages for every order of the batch against the same snapshot of the catalog.
+// @Description Orders that cannot be planned carry an error instead of packages; the other orders are unaffected.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param request body CalculatePackagesBatchRequest true "Request body with the orders and calculation options"
+// @Success 200 {object} CalculatePackagesBatchResponse "Per-order plans or errors, in request order"
+// @Failure 400 {object} string "Invalid request format, options or number of orders"
+// @Failure 500 {object} string "Internal server error"
+// @Router /calculate-packages/batch [post]
+func CalculatePackagesBatch(packagingService service.PackageService, defaults usecase.CalculateOptions, history *usecase.OrderHistory) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var batchRequest CalculatePackagesBatchRequest
+		err := json.NewDecoder(r.Body).Decode(&batchRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(batchRequest.Orders) == 0 || len(batchRequest.Orders) > maxBatchOrders {
+			http.Error(w, fmt.Sprintf("A batch must contain between 1 and %d orders", maxBatchOrders), http.StatusBadRequest)
+			return
+		}
+		options, err := batchRequest.CalculationOptions.apply(defaults)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders := make([]usecase.BatchOrder, 0, len(batchRequest.Orders))
+		for _, order := range batchRequest.Orders {
+			orders = append(orders, usecase.BatchOrder{OrderId: order.OrderId, Sku: order.Sku, Amount: order.Amount})
+		}
+		calculateBatchUsecase := usecase.NewCalculateBatch(packagingService, options)
+		results := calculateBatchUsecase.Execute(r.Context(), orders)
+
+		response := CalculatePackagesBatchResponse{
+			Results: make([]*BatchOrderResult, 0, len(results)),
+		}
+		for i, result := range results {
+			orderResult := &BatchOrderResult{OrderId: result.OrderId}
+			if result.Err != nil {
+				orderResult.Error = result.Err.Error()
+			} else {
+				history.Record(orders[i].Sku, orders[i].Amount)
+				orderResult.Packages = toSizedPackages(result.Result.Plan.Packages)
+				orderResult.Waste = result.Result.Plan.Waste
+				orderResult.NumberOfPackages = result.Result.Plan.NumberOfPackages
+				orderResult.Cost = result.Result.Plan.Cost
+				orderResult.Optimal = result.Result.Optimal
+			}
+			response.Results = append(response.Results, orderResult)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
+	}
+}
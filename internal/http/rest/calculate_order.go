@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+)
+
+// maxOrderLines bounds the lines a single order may carry.
+const maxOrderLines = 1000
+
+type OrderLine struct {
+	Sku    string `json:"sku"`
+	Amount int    `json:"amount"`
+}
+type CalculateOrderRequest struct {
+	Lines []OrderLine `json:"lines"`
+	CalculationOptions
+}
+type LinePlan struct {
+	Sku              string          `json:"sku"`
+	Amount           int             `json:"amount"`
+	Packages         []*SizedPackage `json:"packages"`
+	Waste            int             `json:"waste"`
+	NumberOfPackages int             `json:"numberOfPackages"`
+	Cost             float64         `json:"cost"`
+	Optimal          bool            `json:"optimal"`
+}
+type CalculateOrderResponse struct {
+	Lines            []*LinePlan `json:"lines"`
+	Waste            int         `json:"waste"`
+	NumberOfPackages int         `json:"numberOfPackages"`
+	Cost             float64     `json:"cost"`
+	Optimal          bool        `json:"optimal"`
+}
+
+// CalculateOrder
+// @Summary Calculate required packages for a multi-product order
+// @Description Calculate a plan for every line of the order with the pack sizes of the line's sku, plus the order totals.
+// @Description A sku may appear on a single line only. The calculation options apply to every line.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param request body CalculateOrderRequest true "Request body with the order lines and calculation options"
+// @Success 200 {object} CalculateOrderResponse "Plans of every line and order totals"
+// @Failure 400 {object} string "Invalid request format, options, amount or repeated sku"
+// @Failure 409 {object} string "No package sizes configured for a sku"
+// @Failure 422 {object} UnfulfillableResponse "A line cannot be fulfilled within the waste limits, or is too large"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Calculation cancelled or timed out"
+// @Router /calculate-order [post]
+func CalculateOrder(packagingService service.PackageService, defaults usecase.CalculateOptions, history *usecase.OrderHistory) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var calculateOrderRequest CalculateOrderRequest
+		err := json.NewDecoder(r.Body).Decode(&calculateOrderRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(calculateOrderRequest.Lines) == 0 || len(calculateOrderRequest.Lines) > maxOrderLines {
+			http.Error(w, fmt.Sprintf("An order must contain between 1 and %d lines", maxOrderLines), http.StatusBadRequest)
+			return
+		}
+		options, err := calculateOrderRequest.CalculationOptions.apply(defaults)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lines := make([]usecase.OrderLine, 0, len(calculateOrderRequest.Lines))
+		for _, line := range calculateOrderRequest.Lines {
+			lines = append(lines, usecase.OrderLine{Sku: line.Sku, Amount: line.Amount})
+		}
+		calculateOrderUsecase := usecase.NewCalculateOrder(packagingService, options)
+		order, err := calculateOrderUsecase.Execute(r.Context(), lines)
+		if err != nil {
+			writeCalculationError(w, err)
+			return
+		}
+
+		response := CalculateOrderResponse{
+			Lines:            make([]*LinePlan, 0, len(order.Lines)),
+			Waste:            order.Waste,
+			NumberOfPackages: order.NumberOfPackages,
+			Cost:             order.Cost,
+			Optimal:          order.Optimal,
+		}
+		for _, line := range order.Lines {
+			history.Record(line.Sku, line.Amount)
+			response.Lines = append(response.Lines, &LinePlan{
+				Sku:              line.Sku,
+				Amount:           line.Amount,
+				Packages:         toSizedPackages(line.Plan.Packages),
+				Waste:            line.Plan.Waste,
+				NumberOfPackages: line.Plan.NumberOfPackages,
+				Cost:             line.Plan.Cost,
+				Optimal:          line.Optimal,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
+	}
+}
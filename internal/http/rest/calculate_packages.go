@@ -1,35 +1,124 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
 	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/solver"
 	"github/ahmedghazey/packaging/internal/usecase"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// maxAlternatives bounds the runner-up plans a single request may ask for.
+const maxAlternatives = 20
+
+// CalculationOptions are the request fields tuning how plans are computed.
+type CalculationOptions struct {
+	Strategy        string  `json:"strategy,omitempty"`
+	UseStock        bool    `json:"useStock,omitempty"`
+	Objective       string  `json:"objective,omitempty"`
+	MaxWaste        int     `json:"maxWaste,omitempty"`
+	MaxWastePercent float64 `json:"maxWastePercent,omitempty"`
+	ExactOnly       bool    `json:"exactOnly,omitempty"`
+}
 type CalculatePackagesRequest struct {
-	Amount int `json:"amount"`
+	Sku      string    `json:"sku,omitempty"`
+	Amount   int       `json:"amount"`
+	Partial  bool      `json:"partial,omitempty"`
+	Capacity *Capacity `json:"capacity,omitempty"`
+	CalculationOptions
+}
+type Capacity struct {
+	MaxItems  int     `json:"maxItems,omitempty"`
+	MaxPacks  int     `json:"maxPacks,omitempty"`
+	MaxWeight float64 `json:"maxWeight,omitempty"`
 }
 type SizedPackage struct {
 	Quantity int `json:"quantity"`
 	Size     int `json:"size"`
 }
+type PackingPlan struct {
+	Packages         []*SizedPackage `json:"packages"`
+	Waste            int             `json:"waste"`
+	NumberOfPackages int             `json:"numberOfPackages"`
+	Cost             float64         `json:"cost"`
+}
+type Shipment struct {
+	Packages         []*SizedPackage `json:"packages"`
+	Count            int             `json:"count"`
+	Items            int             `json:"items"`
+	NumberOfPackages int             `json:"numberOfPackages"`
+	Weight           float64         `json:"weight"`
+}
+type RunnerUp struct {
+	Plan      *PackingPlan `json:"plan"`
+	DecidedBy string       `json:"decidedBy"`
+}
+type EliminatedPlan struct {
+	Constraint string       `json:"constraint"`
+	Plan       *PackingPlan `json:"plan"`
+}
+type Explanation struct {
+	Objective        string            `json:"objective"`
+	Waste            int               `json:"waste"`
+	NumberOfPackages int               `json:"numberOfPackages"`
+	RunnersUp        []*RunnerUp       `json:"runnersUp"`
+	Eliminated       []*EliminatedPlan `json:"eliminated"`
+}
+type UnfulfillableResponse struct {
+	Error    string       `json:"error"`
+	Amount   int          `json:"amount"`
+	MaxWaste int          `json:"maxWaste"`
+	Under    *PackingPlan `json:"underFill,omitempty"`
+	Over     *PackingPlan `json:"overFill"`
+}
 type CalculatePackagesResponse struct {
-	Packages []*SizedPackage `json:"packages"`
+	Packages     []*SizedPackage `json:"packages"`
+	Cost         float64         `json:"cost"`
+	Optimal      bool            `json:"optimal"`
+	Alternatives []*PackingPlan  `json:"alternatives,omitempty"`
+	Explanation  *Explanation    `json:"explanation,omitempty"`
+	Backordered  int             `json:"backordered"`
+	Shipments    []*Shipment     `json:"shipments,omitempty"`
 }
 
 // CalculatePackages
 // @Summary Calculate required packages
-// @Description Calculate the minimum number of packages required for a given amount of items
+// @Description Calculate the minimum number of packages required for a given amount of items.
+// @Description The optional sku selects the product whose pack sizes are used, the default catalog otherwise.
+// @Description The optional strategy (exact, greedy, branch-and-bound, heuristic) overrides the configured one.
+// @Description With useStock, no more packs of a size are planned than are in stock.
+// @Description The objective overrides the configured ranking: a preset (minimize_waste, minimize_cost, minimize_packs),
+// @Description criteria in priority order such as "packs,waste", or weights such as "waste:1,packs:250",
+// @Description over waste, packs, cost and distinct_sizes. Plans shipping more than maxWaste surplus items, or more than
+// @Description maxWastePercent of the amount, are rejected, and exactOnly rejects any surplus. These only tighten the
+// @Description limits configured for the catalog. When they leave no plan, the 422 response lists the closest plans.
+// @Description With partial, the largest quantity not exceeding the amount, within stock when useStock is set, is shipped
+// @Description and the rest is reported as backordered.
+// @Description A capacity (maxItems, maxPacks, maxWeight per shipment, using the weights of the packs) splits the plan
+// @Description into shipments; identical shipments are listed once with their count. Plans of up to 40 packs use the
+// @Description fewest shipments; larger ones fill each shipment in turn, largest packs first, which only guarantees the
+// @Description fewest when the pack sizes divide one another.
+// @Description When the search exceeds the configured compute budget a greedy plan is returned with optimal set to false.
 // @Tags Packages
 // @Accept json
 // @Produce json
 // @Param request body CalculatePackagesRequest true "Request body with the amount of items"
+// @Param alternatives query int false "Number of runner-up plans to return, up to 20"
+// @Param explain query bool false "Explain the choice: the runner-up plans beaten and on which criterion, and the plans ruled out by stock or maxWaste"
 // @Success 200 {object} CalculatePackagesResponse "Minimum number of packages calculated successfully"
 // @Failure 400 {object} string "Invalid request format or amount"
+// @Failure 409 {object} string "No package sizes configured"
+// @Failure 422 {object} UnfulfillableResponse "Amount cannot be fulfilled within the waste limits, is too large, or a pack exceeds the capacity"
 // @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Calculation cancelled or timed out"
 // @Router /calculate-packages [post]
-func CalculatePackages(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+func CalculatePackages(packagingService service.PackageService, defaults usecase.CalculateOptions, history *usecase.OrderHistory) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var calculatePackagesRequest CalculatePackagesRequest
 		err := json.NewDecoder(r.Body).Decode(&calculatePackagesRequest)
@@ -42,18 +131,178 @@ func CalculatePackages(packagingService service.PackageService) func(w http.Resp
 			return
 		}
 
-		calculatePackagesUsecase := usecase.NewCalculatePackages(packagingService)
-		sizedPackages := calculatePackagesUsecase.Execute(calculatePackagesRequest.Amount)
-		response := CalculatePackagesResponse{}
-		for _, sizedPackage := range sizedPackages {
-			response.Packages = append(response.Packages, &SizedPackage{
-				Quantity: sizedPackage.Quantity,
-				Size:     sizedPackage.Size,
+		options, err := calculatePackagesRequest.CalculationOptions.apply(defaults)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		options.Partial = calculatePackagesRequest.Partial
+		if capacity := calculatePackagesRequest.Capacity; capacity != nil {
+			if capacity.MaxItems < 0 || capacity.MaxPacks < 0 || capacity.MaxWeight < 0 {
+				http.Error(w, "Capacity limits must not be negative", http.StatusBadRequest)
+				return
+			}
+			options.Capacity = domain.Capacity{MaxItems: capacity.MaxItems, MaxPacks: capacity.MaxPacks, MaxWeight: capacity.MaxWeight}
+		}
+		if value := r.URL.Query().Get("alternatives"); value != "" {
+			options.Alternatives, err = strconv.Atoi(value)
+			if err != nil || options.Alternatives < 0 || options.Alternatives > maxAlternatives {
+				http.Error(w, fmt.Sprintf("Alternatives must be an integer between 0 and %d", maxAlternatives), http.StatusBadRequest)
+				return
+			}
+		}
+		if value := r.URL.Query().Get("explain"); value != "" {
+			options.Explain, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Explain must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+
+		calculatePackagesUsecase := usecase.NewCalculatePackages(packagingService, options)
+		result, err := calculatePackagesUsecase.CalculateProduct(r.Context(), calculatePackagesRequest.Sku, calculatePackagesRequest.Amount)
+		if err != nil {
+			writeCalculationError(w, err)
+			return
+		}
+		history.Record(calculatePackagesRequest.Sku, calculatePackagesRequest.Amount)
+		response := CalculatePackagesResponse{
+			Packages:    toSizedPackages(result.Plan.Packages),
+			Cost:        result.Plan.Cost,
+			Optimal:     result.Optimal,
+			Backordered: result.Backordered,
+		}
+		for _, alternative := range result.Alternatives {
+			response.Alternatives = append(response.Alternatives, toPackingPlan(alternative))
+		}
+		for _, shipment := range result.Shipments {
+			response.Shipments = append(response.Shipments, &Shipment{
+				Packages:         toSizedPackages(shipment.Packages),
+				Count:            shipment.Count,
+				Items:            shipment.Items,
+				NumberOfPackages: shipment.NumberOfPackages,
+				Weight:           shipment.Weight,
 			})
 		}
+		if explanation := result.Explanation; explanation != nil {
+			response.Explanation = &Explanation{
+				Objective:        explanation.Objective,
+				Waste:            explanation.Waste,
+				NumberOfPackages: explanation.NumberOfPackages,
+				RunnersUp:        make([]*RunnerUp, 0, len(explanation.RunnersUp)),
+				Eliminated:       make([]*EliminatedPlan, 0, len(explanation.Eliminated)),
+			}
+			for _, runnerUp := range explanation.RunnersUp {
+				response.Explanation.RunnersUp = append(response.Explanation.RunnersUp, &RunnerUp{
+					Plan:      toPackingPlan(runnerUp.Plan),
+					DecidedBy: string(runnerUp.DecidedBy),
+				})
+			}
+			for _, eliminated := range explanation.Eliminated {
+				response.Explanation.Eliminated = append(response.Explanation.Eliminated, &EliminatedPlan{
+					Constraint: eliminated.Constraint,
+					Plan:       toPackingPlan(eliminated.Plan),
+				})
+			}
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// apply overrides the configured defaults with the request's options. Waste
+// limits can only be tightened.
+func (o CalculationOptions) apply(defaults usecase.CalculateOptions) (usecase.CalculateOptions, error) {
+	options := defaults
+	options.UseStock = o.UseStock
+	if o.MaxWaste < 0 {
+		return options, errors.New("Max waste must not be negative")
+	}
+	if o.MaxWastePercent < 0 {
+		return options, errors.New("Max waste percent must not be negative")
+	}
+	if o.MaxWaste > 0 && (options.MaxWaste <= 0 || o.MaxWaste < options.MaxWaste) {
+		options.MaxWaste = o.MaxWaste
+	}
+	if o.MaxWastePercent > 0 && (options.MaxWastePercent <= 0 || o.MaxWastePercent < options.MaxWastePercent) {
+		options.MaxWastePercent = o.MaxWastePercent
+	}
+	options.ExactOnly = options.ExactOnly || o.ExactOnly
+	if o.Objective != "" {
+		objective, err := solver.ParseObjective(o.Objective)
+		if err != nil {
+			return options, fmt.Errorf("Invalid objective: %s, expected a preset (%s), ordered criteria or weighted criteria",
+				err, strings.Join(solver.PresetNames(), ", "))
+		}
+		options.Objective = objective
+	}
+	if o.Strategy != "" {
+		if _, ok := solver.Get(o.Strategy); !ok {
+			return options, fmt.Errorf("Unknown strategy %q, expected one of: %s", o.Strategy, strings.Join(solver.Names(), ", "))
+		}
+		options.Strategy = o.Strategy
+	}
+	return options, nil
+}
+
+func toPackingPlan(plan domain.PackingPlan) *PackingPlan {
+	return &PackingPlan{
+		Packages:         toSizedPackages(plan.Packages),
+		Waste:            plan.Waste,
+		NumberOfPackages: plan.NumberOfPackages,
+		Cost:             plan.Cost,
+	}
+}
+
+func toSizedPackages(sizedPackages []*domain.SizedPackage) []*SizedPackage {
+	packages := make([]*SizedPackage, 0, len(sizedPackages))
+	for _, sizedPackage := range sizedPackages {
+		packages = append(packages, &SizedPackage{
+			Quantity: sizedPackage.Quantity,
+			Size:     sizedPackage.Size,
+		})
+	}
+	return packages
+}
+
+// writeCalculationError answers with the closest plans when the waste limits
+// left none, and with the error's text otherwise.
+func writeCalculationError(w http.ResponseWriter, err error) {
+	var unfulfillable *domain.UnfulfillableError
+	if !errors.As(err, &unfulfillable) {
+		http.Error(w, err.Error(), calculationErrorStatus(err))
+		return
+	}
+	response := UnfulfillableResponse{
+		Error:    err.Error(),
+		Amount:   unfulfillable.Amount,
+		MaxWaste: unfulfillable.MaxWaste,
+		Over:     toPackingPlan(unfulfillable.Over),
+	}
+	if unfulfillable.Under != nil {
+		response.Under = toPackingPlan(*unfulfillable.Under)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(response)
+}
+
+// calculationErrorStatus maps calculation failures to HTTP status codes.
+func calculationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount), errors.Is(err, domain.ErrDuplicateOrderLine):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrNoPackageSizes), errors.Is(err, domain.ErrInsufficientStock):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrAmountUnreachable), errors.Is(err, domain.ErrAmountTooLarge),
+		errors.Is(err, domain.ErrPackExceedsCapacity), errors.Is(err, domain.ErrCatalogTooLarge):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,294 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+)
+
+type PackageResponse struct {
+	Id     string  `json:"id"`
+	Sku    string  `json:"sku,omitempty"`
+	Size   int     `json:"size"`
+	Stock  int     `json:"stock"`
+	Cost   float64 `json:"cost"`
+	Weight float64 `json:"weight"`
+}
+
+// PatchPackageRequest lists the fields to change; absent fields are kept.
+type PatchPackageRequest struct {
+	Sku    *string  `json:"sku,omitempty"`
+	Size   *int     `json:"size,omitempty"`
+	Stock  *int     `json:"stock,omitempty"`
+	Cost   *float64 `json:"cost,omitempty"`
+	Weight *float64 `json:"weight,omitempty"`
+}
+
+// ListPackages
+// @Summary List packages
+// @Description List the packages of every sku, or only those of the sku query parameter (empty for the default catalog),
+// @Description largest size first.
+// @Tags Packages
+// @Produce json
+// @Param sku query string false "Product whose packages are listed"
+// @Success 200 {array} PackageResponse "The packages"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages [get]
+func ListPackages(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packages, err := packagingService.GetAllPackages()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Has("sku") {
+			packages = domain.ProductCatalog(packages, r.URL.Query().Get("sku"))
+		}
+
+		response := make([]PackageResponse, 0, len(packages))
+		for _, pkg := range packages {
+			response = append(response, toPackageResponse(pkg))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreatePackage
+// @Summary Create a package
+// @Description Add one package size to the catalog of its sku, the default catalog without one.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param request body Package true "The package"
+// @Success 201 {object} PackageResponse "Package created"
+// @Failure 400 {object} string "Invalid request format or package"
+// @Failure 409 {object} string "The sku already has a package of this size"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages [post]
+func CreatePackage(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pkg Package
+		if err := json.NewDecoder(r.Body).Decode(&pkg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := pkg.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		created := pkg.toDomain()
+		addPackagesUsecase := usecase.NewAddPackages(packagingService)
+		if err := addPackagesUsecase.Execute([]*domain.Package{created}); err != nil {
+			http.Error(w, err.Error(), packageErrorStatus(err))
+			return
+		}
+		w.Header().Set("Location", "/packages/"+created.Id)
+		writePackage(w, http.StatusCreated, created)
+	}
+}
+
+// ReplacePackages
+// @Summary Replace the catalog
+// @Description Replace every package of every sku with the given ones, all or none. Packages keep the ID of the package
+// @Description of the same sku and size they replace. The catalog cannot be replaced while allocations are reserved.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param request body []Package true "The new catalog"
+// @Success 200 {array} PackageResponse "Catalog replaced"
+// @Failure 400 {object} BatchErrorResponse "Invalid request format or packages"
+// @Failure 409 {object} BatchErrorResponse "Sizes repeated for a sku, or allocations are reserved"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages [put]
+func ReplacePackages(allocations *usecase.Allocations) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request []Package
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		packages, err := toDomainPackages(request)
+		if err != nil {
+			writePackagesError(w, http.StatusBadRequest, request, err)
+			return
+		}
+		if err = allocations.ReplaceCatalog(packages...); err != nil {
+			writePackagesError(w, packageErrorStatus(err), request, err)
+			return
+		}
+
+		response := make([]PackageResponse, 0, len(packages))
+		for _, pkg := range packages {
+			response = append(response, toPackageResponse(pkg))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetPackage
+// @Summary Get a package
+// @Tags Packages
+// @Produce json
+// @Param id path string true "Package ID"
+// @Success 200 {object} PackageResponse "The package"
+// @Failure 404 {object} string "Package not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages/{id} [get]
+func GetPackage(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pkg, err := packagingService.GetPackage(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), packageErrorStatus(err))
+			return
+		}
+		writePackage(w, http.StatusOK, pkg)
+	}
+}
+
+// ReplacePackage
+// @Summary Replace a package
+// @Description Replace every field of a package; fields left out are reset.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param id path string true "Package ID"
+// @Param request body Package true "The package"
+// @Success 200 {object} PackageResponse "Package replaced"
+// @Failure 400 {object} string "Invalid request format or package"
+// @Failure 404 {object} string "Package not found"
+// @Failure 409 {object} string "The sku already has a package of this size"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages/{id} [put]
+func ReplacePackage(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pkg Package
+		if err := json.NewDecoder(r.Body).Decode(&pkg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := pkg.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated := pkg.toDomain()
+		updated.Id = chi.URLParam(r, "id")
+		if err := packagingService.UpdatePackage(updated.Id, updated); err != nil {
+			http.Error(w, err.Error(), packageErrorStatus(err))
+			return
+		}
+		writePackage(w, http.StatusOK, updated)
+	}
+}
+
+// PatchPackage
+// @Summary Update a package
+// @Description Change the given fields of a package in one write and keep the others, such as stock reserved meanwhile.
+// @Tags Packages
+// @Accept json
+// @Produce json
+// @Param id path string true "Package ID"
+// @Param request body PatchPackageRequest true "The fields to change"
+// @Success 200 {object} PackageResponse "Package updated"
+// @Failure 400 {object} string "Invalid request format or package"
+// @Failure 404 {object} string "Package not found"
+// @Failure 409 {object} string "The sku already has a package of this size"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages/{id} [patch]
+func PatchPackage(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var patch PatchPackageRequest
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := patch.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := packagingService.PatchPackage(chi.URLParam(r, "id"), patch.toDomain())
+		if err != nil {
+			http.Error(w, err.Error(), packageErrorStatus(err))
+			return
+		}
+		writePackage(w, http.StatusOK, updated)
+	}
+}
+
+// DeletePackage
+// @Summary Delete a package
+// @Tags Packages
+// @Param id path string true "Package ID"
+// @Success 204 "Package deleted"
+// @Failure 404 {object} string "Package not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /packages/{id} [delete]
+func DeletePackage(packagingService service.PackageService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := packagingService.DeletePackage(chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), packageErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validate checks the fields to change like Package.validate; the fields kept
+// are valid already.
+func (p PatchPackageRequest) validate() error {
+	pkg := Package{Size: 1}
+	if p.Size != nil {
+		pkg.Size = *p.Size
+	}
+	if p.Stock != nil {
+		pkg.Stock = *p.Stock
+	}
+	if p.Cost != nil {
+		pkg.Cost = *p.Cost
+	}
+	if p.Weight != nil {
+		pkg.Weight = *p.Weight
+	}
+	return pkg.validate()
+}
+
+func (p PatchPackageRequest) toDomain() domain.PackagePatch {
+	return domain.PackagePatch{Sku: p.Sku, Size: p.Size, Stock: p.Stock, Cost: p.Cost, Weight: p.Weight}
+}
+
+func toPackageResponse(pkg *domain.Package) PackageResponse {
+	return PackageResponse{
+		Id:     pkg.Id,
+		Sku:    pkg.Sku,
+		Size:   pkg.Size,
+		Stock:  pkg.Stock,
+		Cost:   pkg.Cost,
+		Weight: pkg.Weight,
+	}
+}
+
+func writePackage(w http.ResponseWriter, status int, pkg *domain.Package) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(toPackageResponse(pkg))
+}
+
+// packageErrorStatus maps catalog failures to HTTP status codes.
+func packageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrPackageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrDuplicatePackageSize), errors.Is(err, domain.ErrAllocationsReserved):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"encoding/json"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"net/http"
+)
+
+type PlanCacheStatsResponse struct {
+	Enabled   bool   `json:"enabled"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+}
+
+// PlanCacheStats
+// @Summary Plan cache statistics
+// @Description Hit, miss and eviction counters of the calculation result cache, for monitoring.
+// @Description The cache is disabled when PLAN_CACHE_SIZE is 0.
+// @Tags Monitoring
+// @Produce json
+// @Success 200 {object} PlanCacheStatsResponse "Cache counters"
+// @Router /stats/plan-cache [get]
+func PlanCacheStats(planCache *usecase.PlanCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := PlanCacheStatsResponse{}
+		if planCache != nil {
+			stats := planCache.Stats()
+			response = PlanCacheStatsResponse{
+				Enabled:   true,
+				Hits:      stats.Hits,
+				Misses:    stats.Misses,
+				Evictions: stats.Evictions,
+				Size:      stats.Size,
+				Capacity:  stats.Capacity,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RecommendSizesRequest struct {
+	Orders   []SimulatedOrder `json:"orders"`
+	MaxSizes int              `json:"maxSizes"`
+	MinSize  int              `json:"minSize,omitempty"`
+	MaxSize  int              `json:"maxSize,omitempty"`
+	Step     int              `json:"step,omitempty"`
+}
+type RecommendationJobResponse struct {
+	Id          string            `json:"id"`
+	Status      string            `json:"status"`
+	Sizes       []int             `json:"sizes,omitempty"`
+	Totals      *SimulationTotals `json:"totals,omitempty"`
+	Evaluations int               `json:"evaluations"`
+	Error       string            `json:"error,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+	FinishedAt  *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// RecommendSizes
+// @Summary Recommend pack sizes
+// @Description Start searching, in the background, for the set of at most maxSizes pack sizes that ships the order
+// @Description history with the least overshoot, then the fewest packs. The candidates are the multiples of step from
+// @Description minSize to maxSize, or the ordered amounts within those bounds when no step is given. The search runs for
+// @Description at most RECOMMENDATION_TIMEOUT; poll the returned job for the best sizes found so far. Orders can also be
+// @Description uploaded as CSV (Content-Type text/csv) with one "amount,count" row per order, the search bounds then
+// @Description being given as query parameters.
+// @Tags Recommendations
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param request body RecommendSizesRequest true "Request body with the orders and search bounds"
+// @Param maxSizes query int false "Number of sizes to recommend for CSV uploads"
+// @Param minSize query int false "Smallest candidate size for CSV uploads"
+// @Param maxSize query int false "Largest candidate size for CSV uploads"
+// @Param step query int false "Candidate size step for CSV uploads"
+// @Success 202 {object} RecommendationJobResponse "Recommendation started"
+// @Failure 400 {object} string "Invalid request format, orders or search bounds, or too many candidate sizes"
+// @Failure 429 {object} string "Too many recommendations running"
+// @Router /recommendations [post]
+func RecommendSizes(jobs *usecase.RecommendationJobs) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var recommendRequest RecommendSizesRequest
+		var err error
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			recommendRequest, err = readRecommendationCsv(r)
+		} else {
+			err = json.NewDecoder(r.Body).Decode(&recommendRequest)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(recommendRequest.Orders) == 0 || len(recommendRequest.Orders) > maxSimulatedOrders {
+			http.Error(w, fmt.Sprintf("A recommendation must be based on between 1 and %d orders", maxSimulatedOrders), http.StatusBadRequest)
+			return
+		}
+		if recommendRequest.MinSize < 0 || recommendRequest.MaxSize < 0 || recommendRequest.Step < 0 {
+			http.Error(w, "Size bounds and step must not be negative", http.StatusBadRequest)
+			return
+		}
+		orders := make([]usecase.SimulatedOrder, 0, len(recommendRequest.Orders))
+		for _, order := range recommendRequest.Orders {
+			if order.Amount <= 0 || order.Count < 0 {
+				http.Error(w, "Order amounts must be positive and counts must not be negative", http.StatusBadRequest)
+				return
+			}
+			orders = append(orders, usecase.SimulatedOrder{Amount: order.Amount, Count: max(order.Count, 1)})
+		}
+
+		recommendSizesUsecase := usecase.NewRecommendSizes(recommendRequest.MaxSizes, recommendRequest.MinSize, recommendRequest.MaxSize, recommendRequest.Step)
+		job, err := jobs.Start(recommendSizesUsecase, orders)
+		if errors.Is(err, domain.ErrTooManyRecommendations) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeRecommendationJob(w, http.StatusAccepted, job)
+	}
+}
+
+// GetRecommendation
+// @Summary Get a recommendation
+// @Description Get the status (running, done, stopped or failed) of a recommendation and the best sizes found so far.
+// @Tags Recommendations
+// @Produce json
+// @Param id path string true "Recommendation ID"
+// @Success 200 {object} RecommendationJobResponse "The recommendation"
+// @Failure 404 {object} string "Recommendation not found"
+// @Router /recommendations/{id} [get]
+func GetRecommendation(jobs *usecase.RecommendationJobs) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := jobs.Get(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeRecommendationJob(w, http.StatusOK, job)
+	}
+}
+
+// CancelRecommendation
+// @Summary Cancel a recommendation
+// @Description Stop a running recommendation, which keeps the best sizes found so far. Finished ones are left as they are.
+// @Tags Recommendations
+// @Produce json
+// @Param id path string true "Recommendation ID"
+// @Success 200 {object} RecommendationJobResponse "Recommendation cancelled"
+// @Failure 404 {object} string "Recommendation not found"
+// @Router /recommendations/{id}/cancel [post]
+func CancelRecommendation(jobs *usecase.RecommendationJobs) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := jobs.Cancel(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeRecommendationJob(w, http.StatusOK, job)
+	}
+}
+
+// readRecommendationCsv reads orders uploaded as CSV, see
+// usecase.ReadOrdersCsv, and the search bounds from the query parameters.
+func readRecommendationCsv(r *http.Request) (RecommendSizesRequest, error) {
+	var recommendRequest RecommendSizesRequest
+	query := r.URL.Query()
+	for _, bound := range []struct {
+		name  string
+		value *int
+	}{
+		{"maxSizes", &recommendRequest.MaxSizes},
+		{"minSize", &recommendRequest.MinSize},
+		{"maxSize", &recommendRequest.MaxSize},
+		{"step", &recommendRequest.Step},
+	} {
+		if !query.Has(bound.name) {
+			continue
+		}
+		value, err := strconv.Atoi(query.Get(bound.name))
+		if err != nil {
+			return recommendRequest, fmt.Errorf("invalid %s %q", bound.name, query.Get(bound.name))
+		}
+		*bound.value = value
+	}
+
+	orders, err := usecase.ReadOrdersCsv(r.Body)
+	if err != nil {
+		return recommendRequest, err
+	}
+	for _, order := range orders {
+		recommendRequest.Orders = append(recommendRequest.Orders, SimulatedOrder{Amount: order.Amount, Count: order.Count})
+	}
+	return recommendRequest, nil
+}
+
+func writeRecommendationJob(w http.ResponseWriter, status int, job usecase.RecommendationJob) {
+	response := RecommendationJobResponse{
+		Id:        job.Id,
+		Status:    string(job.Status),
+		StartedAt: job.StartedAt,
+	}
+	if job.Best != nil {
+		totals := SimulationTotals(job.Best.Totals)
+		response.Sizes = job.Best.Sizes
+		response.Totals = &totals
+		response.Evaluations = job.Best.Evaluations
+	}
+	if job.Err != nil {
+		response.Error = job.Err.Error()
+	}
+	if !job.FinishedAt.IsZero() {
+		response.FinishedAt = &job.FinishedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/usecase"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxSimulatedOrders bounds the distinct order amounts a simulation may
+// carry.
+const maxSimulatedOrders = 10000
+
+type SimulatedOrder struct {
+	Amount int `json:"amount"`
+	Count  int `json:"count,omitempty"`
+}
+type SimulateCatalogRequest struct {
+	Sku       string           `json:"sku,omitempty"`
+	Baseline  []Package        `json:"baseline,omitempty"`
+	Candidate []Package        `json:"candidate"`
+	Orders    []SimulatedOrder `json:"orders,omitempty"`
+	CalculationOptions
+}
+type SimulationTotals struct {
+	Orders           int     `json:"orders"`
+	Overshoot        int     `json:"overshoot"`
+	NumberOfPackages int     `json:"numberOfPackages"`
+	Cost             float64 `json:"cost"`
+	Unfulfilled      int     `json:"unfulfilled"`
+}
+type OrderComparison struct {
+	Amount         int          `json:"amount"`
+	Count          int          `json:"count"`
+	Baseline       *PackingPlan `json:"baseline"`
+	Candidate      *PackingPlan `json:"candidate"`
+	BaselineError  string       `json:"baselineError,omitempty"`
+	CandidateError string       `json:"candidateError,omitempty"`
+	WasteDelta     int          `json:"wasteDelta"`
+	PacksDelta     int          `json:"packsDelta"`
+	CostDelta      float64      `json:"costDelta"`
+}
+type SimulateCatalogResponse struct {
+	Baseline  SimulationTotals   `json:"baseline"`
+	Candidate SimulationTotals   `json:"candidate"`
+	Orders    []*OrderComparison `json:"orders"`
+}
+
+// SimulateCatalog
+// @Summary Simulate a catalog change
+// @Description Plan historical orders with a baseline and a candidate set of pack sizes and compare the total overshoot,
+// @Description packs and cost of both, and the plans of every order. The live catalog is neither used for the candidate
+// @Description nor changed; the baseline defaults to the live pack sizes of the sku. Orders carry the number of times
+// @Description the amount was placed, 1 by default. They can also be uploaded as CSV (Content-Type text/csv) with one
+// @Description "amount,count" row per order, the pack sizes then being given as comma-separated query parameters.
+// @Description Without orders, the orders of the sku planned by the calculation endpoints since the service started are
+// @Description simulated.
+// @Tags Packages
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param request body SimulateCatalogRequest true "Request body with the pack size sets, orders and calculation options"
+// @Param baseline query string false "Baseline pack sizes for CSV uploads, e.g. 250,500,1000"
+// @Param candidate query string false "Candidate pack sizes for CSV uploads, e.g. 250,500,750"
+// @Success 200 {object} SimulateCatalogResponse "Totals of both catalogs and per-order comparisons"
+// @Failure 400 {object} string "Invalid request format, pack sizes, options or orders"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Simulation cancelled or timed out"
+// @Router /simulations [post]
+func SimulateCatalog(packagingService service.PackageService, defaults usecase.CalculateOptions, history *usecase.OrderHistory) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var simulateRequest SimulateCatalogRequest
+		var err error
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			simulateRequest, err = readSimulationCsv(r)
+		} else {
+			err = json.NewDecoder(r.Body).Decode(&simulateRequest)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(simulateRequest.Orders) == 0 {
+			for _, order := range history.Orders(simulateRequest.Sku) {
+				simulateRequest.Orders = append(simulateRequest.Orders, SimulatedOrder{Amount: order.Amount, Count: order.Count})
+			}
+		}
+		if len(simulateRequest.Orders) == 0 || len(simulateRequest.Orders) > maxSimulatedOrders {
+			http.Error(w, fmt.Sprintf("A simulation must contain between 1 and %d orders, given or recorded for the sku", maxSimulatedOrders), http.StatusBadRequest)
+			return
+		}
+		orders := make([]usecase.SimulatedOrder, 0, len(simulateRequest.Orders))
+		for _, order := range simulateRequest.Orders {
+			if order.Amount <= 0 || order.Count < 0 {
+				http.Error(w, "Order amounts must be positive and counts must not be negative", http.StatusBadRequest)
+				return
+			}
+			orders = append(orders, usecase.SimulatedOrder{Amount: order.Amount, Count: max(order.Count, 1)})
+		}
+		candidate, err := toSimulatedCatalog(simulateRequest.Candidate)
+		if err != nil || len(candidate) == 0 {
+			http.Error(w, "The candidate must list at least one positive pack size", http.StatusBadRequest)
+			return
+		}
+		packages, err := packagingService.GetAllPackages()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		baseline := domain.ProductCatalog(packages, simulateRequest.Sku)
+		if simulateRequest.Baseline != nil {
+			if baseline, err = toSimulatedCatalog(simulateRequest.Baseline); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		options, err := simulateRequest.CalculationOptions.apply(defaults)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		simulateCatalogUsecase := usecase.NewSimulateCatalog(options)
+		report, err := simulateCatalogUsecase.Execute(r.Context(), baseline, candidate, orders)
+		if err != nil {
+			http.Error(w, err.Error(), calculationErrorStatus(err))
+			return
+		}
+		response := SimulateCatalogResponse{
+			Baseline:  SimulationTotals(report.Baseline),
+			Candidate: SimulationTotals(report.Candidate),
+			Orders:    make([]*OrderComparison, 0, len(report.Orders)),
+		}
+		for _, order := range report.Orders {
+			comparison := &OrderComparison{
+				Amount:     order.Amount,
+				Count:      order.Count,
+				WasteDelta: order.WasteDelta,
+				PacksDelta: order.PacksDelta,
+				CostDelta:  order.CostDelta,
+			}
+			if order.Baseline != nil {
+				comparison.Baseline = toPackingPlan(*order.Baseline)
+			} else {
+				comparison.BaselineError = order.BaselineErr.Error()
+			}
+			if order.Candidate != nil {
+				comparison.Candidate = toPackingPlan(*order.Candidate)
+			} else {
+				comparison.CandidateError = order.CandidateErr.Error()
+			}
+			response.Orders = append(response.Orders, comparison)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// readSimulationCsv reads orders uploaded as CSV, see usecase.ReadOrdersCsv,
+// and the pack sizes from the query parameters.
+func readSimulationCsv(r *http.Request) (SimulateCatalogRequest, error) {
+	query := r.URL.Query()
+	simulateRequest := SimulateCatalogRequest{Sku: query.Get("sku")}
+	var err error
+	if simulateRequest.Candidate, err = parseSizes(query.Get("candidate")); err != nil {
+		return simulateRequest, err
+	}
+	if query.Has("baseline") {
+		if simulateRequest.Baseline, err = parseSizes(query.Get("baseline")); err != nil {
+			return simulateRequest, err
+		}
+	}
+
+	orders, err := usecase.ReadOrdersCsv(r.Body)
+	if err != nil {
+		return simulateRequest, err
+	}
+	for _, order := range orders {
+		simulateRequest.Orders = append(simulateRequest.Orders, SimulatedOrder{Amount: order.Amount, Count: order.Count})
+	}
+	return simulateRequest, nil
+}
+
+func parseSizes(value string) ([]Package, error) {
+	packages := make([]Package, 0)
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		size, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pack size %q", field)
+		}
+		packages = append(packages, Package{Size: size})
+	}
+	return packages, nil
+}
+
+func toSimulatedCatalog(packages []Package) ([]*domain.Package, error) {
+	catalog := make([]*domain.Package, 0, len(packages))
+	for _, pkg := range packages {
+		if pkg.Size <= 0 || pkg.Stock < 0 || pkg.Cost < 0 || pkg.Weight < 0 {
+			return nil, errors.New("Pack sizes must be positive, and stock, cost and weight must not be negative")
+		}
+		catalog = append(catalog, &domain.Package{Size: pkg.Size, Stock: pkg.Stock, Cost: pkg.Cost, Weight: pkg.Weight})
+	}
+	return catalog, nil
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout_CancelsContext(t *testing.T) {
+	var err error
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		err = r.Context().Err()
+	})
+
+	rr := httptest.NewRecorder()
+
+	timeoutMiddleware := Timeout(10 * time.Millisecond)(handler)
+	timeoutMiddleware.ServeHTTP(rr, httptest.NewRequest("GET", "/test", nil))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -1,15 +1,37 @@
 package repository
 
 import (
-	"github.com/google/uuid"
-	"github/ahmedghazey/packaging/internal/storage/inmemory"
+	"github/ahmedghazey/packaging/internal/domain"
 )
 
 // PackageRepository defines the interface for interacting with the storage.
+// Packages are keyed by their Id, and implementations hand out copies, so
+// callers may change the packages they receive.
 type PackageRepository interface {
-	Create(item *inmemory.Package) error
-	Get(id uuid.UUID) (*inmemory.Package, bool)
-	Update(id uuid.UUID, updatedPackage *inmemory.Package) bool
-	Delete(id uuid.UUID) bool
-	GetAllPackages() []*inmemory.Package
+	// Create fails with domain.ErrDuplicatePackageSize when the package's sku
+	// already has a package of its size.
+	Create(item *domain.Package) error
+	// CreateAll creates the packages all or none. When any size is taken,
+	// in the storage or earlier in items, it fails with a *domain.BatchError
+	// listing every such package.
+	CreateAll(items []*domain.Package) error
+	// Get, Update and Delete fail with domain.ErrPackageNotFound for unknown
+	// IDs; Update fails like Create for duplicate sizes.
+	Get(id string) (*domain.Package, error)
+	Update(id string, updatedPackage *domain.Package) error
+	// Patch changes only the patch's fields of the package, atomically with
+	// respect to the other writes, and returns the result. It fails like
+	// Update.
+	Patch(id string, patch domain.PackagePatch) (*domain.Package, error)
+	Delete(id string) error
+	GetAllPackages() ([]*domain.Package, error)
+	// ReplaceAll replaces every package with items at once, failing like
+	// CreateAll without changes when items repeat a size of a sku.
+	ReplaceAll(items []*domain.Package) error
+	// AdjustStock adds the deltas to the packages' stock atomically, failing
+	// with domain.ErrInsufficientStock when any stock would drop below zero.
+	AdjustStock(deltas map[string]int) error
+	// ReturnStock adds the quantities to the packages' stock atomically,
+	// skipping packages that no longer exist.
+	ReturnStock(quantities map[string]int) error
 }
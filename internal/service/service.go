@@ -6,22 +6,43 @@ import (
 	"github.com/google/uuid"
 	"github/ahmedghazey/packaging/internal/domain"
 	"github/ahmedghazey/packaging/internal/repository"
-	"github/ahmedghazey/packaging/internal/storage/inmemory"
 	"slices"
+	"sync/atomic"
 )
 
 type PackageService interface {
+	// CreatePackage creates the packages all or none, failing with a
+	// *domain.BatchError listing every rejected package. Packages without an
+	// ID get theirs once they are written.
 	CreatePackage(...*domain.Package) error
-	GetPackage(id string) (*domain.Package, bool)
-	UpdatePackage(id string, updatedPackage *domain.Package) (bool, error)
-	DeletePackage(id string) bool
-	GetAllPackages() []*domain.Package
+	// GetPackage, UpdatePackage and DeletePackage fail with
+	// domain.ErrPackageNotFound for unknown or malformed IDs.
+	GetPackage(id string) (*domain.Package, error)
+	UpdatePackage(id string, updatedPackage *domain.Package) error
+	// PatchPackage changes only the patch's fields, leaving the others as
+	// they are at the time of the write, and returns the result.
+	PatchPackage(id string, patch domain.PackagePatch) (*domain.Package, error)
+	DeletePackage(id string) error
+	GetAllPackages() ([]*domain.Package, error)
+	// ReplaceCatalog replaces every package with the given ones, all or
+	// none, failing like CreatePackage.
+	ReplaceCatalog(...*domain.Package) error
+	// AdjustStock adds the deltas, keyed by package ID, to the stock of the
+	// packages, all or none.
+	AdjustStock(deltas map[string]int) error
+	// ReturnStock adds the quantities, keyed by package ID, to the stock of
+	// the packages that still exist, all at once.
+	ReturnStock(quantities map[string]int) error
+	// CatalogVersion changes whenever a package is created, updated or
+	// deleted, so results computed from the catalog can be invalidated.
+	CatalogVersion() uint64
 }
 
 var _ PackageService = (*Service)(nil)
 
 type Service struct {
 	repository repository.PackageRepository
+	version    atomic.Uint64
 }
 
 func NewService(repository repository.PackageRepository) *Service {
@@ -31,77 +52,171 @@ func NewService(repository repository.PackageRepository) *Service {
 }
 
 func (s *Service) CreatePackage(items ...*domain.Package) error {
-	for _, pkg := range items {
-		if pkg.Id == "" {
-			pkg.Id = uuid.New().String()
-		}
+	written := clones(items)
+	if err := assignIds(written); err != nil {
+		return fmt.Errorf("failed to create package: %w", err)
+	}
 
-		item, _ := domainToStorage(pkg)
-		err := s.repository.Create(item)
-		if err != nil {
-			return fmt.Errorf("failed to create package: %w", err)
-		}
+	err := s.repository.CreateAll(written)
+	if err != nil {
+		return fmt.Errorf("failed to create package: %w", err)
 	}
+	copyIds(items, written)
+	s.version.Add(1)
 	return nil
 }
-func (s *Service) GetPackage(id string) (*domain.Package, bool) {
-	uuidID, err := uuid.Parse(id)
-	if err != nil {
-		return nil, false
+func (s *Service) GetPackage(id string) (*domain.Package, error) {
+	if err := checkId(id); err != nil {
+		return nil, err
 	}
 
-	storagePkg, found := s.repository.Get(uuidID)
-	if !found {
-		return nil, false
+	pkg, err := s.repository.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
 	}
-
-	return storageToDomain(storagePkg), true
+	return pkg, nil
 }
-func (s *Service) UpdatePackage(id string, updatedPackage *domain.Package) (bool, error) {
-	uuidID, err := uuid.Parse(id)
-	if err != nil {
-		return false, fmt.Errorf("invalid UUID")
+func (s *Service) UpdatePackage(id string, updatedPackage *domain.Package) error {
+	if err := checkId(id); err != nil {
+		return err
 	}
-	storageItem, err := domainToStorage(updatedPackage)
-	if err != nil {
-		return false, fmt.Errorf("failed to convert package to storage")
+	if err := s.repository.Update(id, updatedPackage); err != nil {
+		return fmt.Errorf("failed to update package: %w", err)
 	}
-	return s.repository.Update(uuidID, storageItem), nil
+	s.version.Add(1)
+	return nil
 }
-func (s *Service) DeletePackage(id string) bool {
-	uuidID, err := uuid.Parse(id)
+func (s *Service) PatchPackage(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	if err := checkId(id); err != nil {
+		return nil, err
+	}
+	pkg, err := s.repository.Patch(id, patch)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("failed to update package: %w", err)
 	}
-
-	return s.repository.Delete(uuidID)
+	s.version.Add(1)
+	return pkg, nil
 }
-func (s *Service) GetAllPackages() []*domain.Package {
-	storagePackages := s.repository.GetAllPackages()
-	domainPackages := make([]*domain.Package, 0, len(storagePackages))
+func (s *Service) DeletePackage(id string) error {
+	if err := checkId(id); err != nil {
+		return err
+	}
 
-	for _, storagePkg := range storagePackages {
-		domainPackages = append(domainPackages, storageToDomain(storagePkg))
+	if err := s.repository.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete package: %w", err)
 	}
-	slices.SortFunc(domainPackages, func(a, b *domain.Package) int {
+	s.version.Add(1)
+	return nil
+}
+func (s *Service) GetAllPackages() ([]*domain.Package, error) {
+	packages, err := s.repository.GetAllPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+	slices.SortFunc(packages, func(a, b *domain.Package) int {
 		return cmp.Compare(b.Size, a.Size)
 	})
-	return domainPackages
+	return packages, nil
 }
 
-func domainToStorage(domainPkg *domain.Package) (*inmemory.Package, error) {
-	uuidID, err := uuid.Parse(domainPkg.Id)
+// ReplaceCatalog keeps the ID of the package of the same sku and size for
+// the items without one.
+func (s *Service) ReplaceCatalog(items ...*domain.Package) error {
+	// repeated sizes are rejected before they could share a reused ID
+	if err := domain.CheckBatch(nil, items); err != nil {
+		return fmt.Errorf("failed to replace catalog: %w", err)
+	}
+	packages, err := s.repository.GetAllPackages()
 	if err != nil {
-		return nil, fmt.Errorf("invalid UUID")
+		return fmt.Errorf("failed to replace catalog: %w", err)
 	}
-	return &inmemory.Package{
-		ID:   uuidID,
-		Size: domainPkg.Size,
-	}, nil
+	written := clones(items)
+	for _, item := range written {
+		if item.Id != "" {
+			continue
+		}
+		i := slices.IndexFunc(packages, func(pkg *domain.Package) bool {
+			return pkg.Sku == item.Sku && pkg.Size == item.Size
+		})
+		if i >= 0 {
+			item.Id = packages[i].Id
+		}
+	}
+	if err = assignIds(written); err != nil {
+		return fmt.Errorf("failed to replace catalog: %w", err)
+	}
+
+	if err = s.repository.ReplaceAll(written); err != nil {
+		return fmt.Errorf("failed to replace catalog: %w", err)
+	}
+	copyIds(items, written)
+	s.version.Add(1)
+	return nil
 }
-func storageToDomain(storagePkg *inmemory.Package) *domain.Package {
-	return &domain.Package{
-		Id:   storagePkg.ID.String(),
-		Size: storagePkg.Size,
+
+func (s *Service) AdjustStock(deltas map[string]int) error {
+	if err := s.repository.AdjustStock(deltas); err != nil {
+		return fmt.Errorf("failed to adjust stock: %w", err)
+	}
+	s.version.Add(1)
+	return nil
+}
+
+func (s *Service) ReturnStock(quantities map[string]int) error {
+	if err := s.repository.ReturnStock(quantities); err != nil {
+		return fmt.Errorf("failed to return stock: %w", err)
+	}
+	s.version.Add(1)
+	return nil
+}
+
+func (s *Service) CatalogVersion() uint64 {
+	return s.version.Load()
+}
+
+// assignIds gives the items without an ID a new one, failing with a
+// *domain.BatchError when other IDs are no UUIDs or repeat.
+func assignIds(items []*domain.Package) error {
+	var rejected []domain.RejectedPackage
+	seen := make(map[string]bool, len(items))
+	for i, pkg := range items {
+		if pkg.Id == "" {
+			pkg.Id = uuid.New().String()
+		} else if _, err := uuid.Parse(pkg.Id); err != nil {
+			rejected = append(rejected, domain.RejectedPackage{Index: i, Err: fmt.Errorf("invalid UUID %q", pkg.Id)})
+		} else if seen[pkg.Id] {
+			rejected = append(rejected, domain.RejectedPackage{Index: i, Err: fmt.Errorf("repeated ID %q", pkg.Id)})
+		}
+		seen[pkg.Id] = true
+	}
+	if len(rejected) > 0 {
+		return &domain.BatchError{Rejected: rejected}
+	}
+	return nil
+}
+
+// checkId fails with domain.ErrPackageNotFound for IDs that are no UUIDs,
+// which no package has.
+func checkId(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%w: invalid UUID %q", domain.ErrPackageNotFound, id)
+	}
+	return nil
+}
+
+// clones copies the items, so IDs are only handed to the caller's items once
+// they are written.
+func clones(items []*domain.Package) []*domain.Package {
+	copies := make([]*domain.Package, 0, len(items))
+	for _, item := range items {
+		item := *item
+		copies = append(copies, &item)
+	}
+	return copies
+}
+
+func copyIds(items, written []*domain.Package) {
+	for i, item := range items {
+		item.Id = written[i].Id
 	}
 }
@@ -2,12 +2,9 @@ package service
 
 import (
 	"errors"
-	"fmt"
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github/ahmedghazey/packaging/internal/domain"
-	"github/ahmedghazey/packaging/internal/storage/inmemory"
 	"testing"
 )
 
@@ -16,75 +13,54 @@ type MockPackageRepository struct {
 	mock.Mock
 }
 
-func (m *MockPackageRepository) Create(item *inmemory.Package) error {
+func (m *MockPackageRepository) Create(item *domain.Package) error {
 	args := m.Called(item)
 	return args.Error(0)
 }
-func (m *MockPackageRepository) Get(id uuid.UUID) (*inmemory.Package, bool) {
+func (m *MockPackageRepository) CreateAll(items []*domain.Package) error {
+	args := m.Called(items)
+	return args.Error(0)
+}
+func (m *MockPackageRepository) Get(id string) (*domain.Package, error) {
 	args := m.Called(id)
-	return args.Get(0).(*inmemory.Package), args.Bool(1)
+	return args.Get(0).(*domain.Package), args.Error(1)
 }
-func (m *MockPackageRepository) Update(id uuid.UUID, updatedPackage *inmemory.Package) bool {
+func (m *MockPackageRepository) Update(id string, updatedPackage *domain.Package) error {
 	args := m.Called(id, updatedPackage)
-	return args.Bool(0)
+	return args.Error(0)
 }
-func (m *MockPackageRepository) Delete(id uuid.UUID) bool {
+func (m *MockPackageRepository) Patch(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	args := m.Called(id, patch)
+	return args.Get(0).(*domain.Package), args.Error(1)
+}
+func (m *MockPackageRepository) Delete(id string) error {
 	args := m.Called(id)
-	return args.Bool(0)
+	return args.Error(0)
 }
-func (m *MockPackageRepository) GetAllPackages() []*inmemory.Package {
+func (m *MockPackageRepository) GetAllPackages() ([]*domain.Package, error) {
 	args := m.Called()
-	return args.Get(0).([]*inmemory.Package)
+	return args.Get(0).([]*domain.Package), args.Error(1)
 }
-
-func TestDomainToStorage(t *testing.T) {
-	// Valid UUID string
-	validUUIDStr := "00000000-0000-0000-0000-000000000000"
-	validUUID, _ := uuid.Parse(validUUIDStr)
-
-	testCases := []struct {
-		name           string
-		domainPackage  *domain.Package
-		expectedResult *inmemory.Package
-		expectedError  error
-	}{
-		{
-			name: "Valid Input",
-			domainPackage: &domain.Package{
-				Id:   validUUIDStr,
-				Size: 10,
-			},
-			expectedResult: &inmemory.Package{
-				ID:   validUUID,
-				Size: 10,
-			},
-			expectedError: nil,
-		},
-		{
-			name: "Invalid UUID Format",
-			domainPackage: &domain.Package{
-				Id:   "invalid-uuid-format",
-				Size: 20,
-			},
-			expectedResult: nil,
-			expectedError:  fmt.Errorf("invalid UUID"),
-		},
-	}
-
-	for _, testCase := range testCases {
-		t.Run(testCase.name, func(t *testing.T) {
-			result, err := domainToStorage(testCase.domainPackage)
-			assert.Equal(t, testCase.expectedResult, result, "Result does not match expected")
-			assert.Equal(t, testCase.expectedError, err, "Error does not match expected")
-		})
-	}
+func (m *MockPackageRepository) ReplaceAll(items []*domain.Package) error {
+	args := m.Called(items)
+	return args.Error(0)
+}
+func (m *MockPackageRepository) AdjustStock(deltas map[string]int) error {
+	args := m.Called(deltas)
+	return args.Error(0)
+}
+func (m *MockPackageRepository) ReturnStock(quantities map[string]int) error {
+	args := m.Called(quantities)
+	return args.Error(0)
 }
 
 func TestService_CreatePackage(t *testing.T) {
+	repositoryError := errors.New("mock repository error")
 	testCases := []struct {
 		name           string
 		pkg            *domain.Package
-		expectedError  error
+		mockError      error
+		expectedError  string
 		expectedCalled bool
 	}{
 		{
@@ -93,7 +69,6 @@ func TestService_CreatePackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000001",
 				Size: 10,
 			},
-			expectedError:  nil,
 			expectedCalled: true,
 		},
 		{
@@ -102,7 +77,8 @@ func TestService_CreatePackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000002",
 				Size: 20,
 			},
-			expectedError:  fmt.Errorf("failed to create package: %w", fmt.Errorf("mock repository error")),
+			mockError:      repositoryError,
+			expectedError:  "failed to create package: mock repository error",
 			expectedCalled: true,
 		},
 		{
@@ -111,8 +87,8 @@ func TestService_CreatePackage(t *testing.T) {
 				Id:   "invalid-uuid-format",
 				Size: 20,
 			},
-			expectedError:  fmt.Errorf("failed to create package: %w", fmt.Errorf("mock repository error")),
-			expectedCalled: true,
+			expectedError:  `failed to create package: packages rejected: index 0: invalid UUID "invalid-uuid-format"`,
+			expectedCalled: false,
 		},
 	}
 
@@ -120,33 +96,64 @@ func TestService_CreatePackage(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepository := new(MockPackageRepository)
 			service := NewService(mockRepository)
-			expectedStoragePackage, _ := domainToStorage(tc.pkg)
-			mockRepository.On("Create", expectedStoragePackage).Return(tc.expectedError)
+			mockRepository.On("CreateAll", []*domain.Package{tc.pkg}).Return(tc.mockError)
 
 			err := service.CreatePackage(tc.pkg)
-			if tc.expectedError != nil {
-				assert.Equal(t, fmt.Errorf("failed to create package: %w", tc.expectedError), err)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
 			} else {
 				assert.Nil(t, err)
 			}
 
-			mockRepository.AssertCalled(t, "Create", expectedStoragePackage)
-
 			if tc.expectedCalled {
 				mockRepository.AssertExpectations(t)
 			} else {
-				mockRepository.AssertNotCalled(t, "Create")
+				mockRepository.AssertNotCalled(t, "CreateAll", []*domain.Package{tc.pkg})
 			}
 		})
 	}
 }
 
+func TestService_CreatePackage_RejectsEveryInvalidId(t *testing.T) {
+	mockRepository := new(MockPackageRepository)
+	service := NewService(mockRepository)
+	id := "00000000-0000-0000-0000-000000000001"
+
+	err := service.CreatePackage(
+		&domain.Package{Id: id, Size: 10},
+		&domain.Package{Id: "invalid", Size: 20},
+		&domain.Package{Id: id, Size: 30},
+	)
+
+	var batchErr *domain.BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{1, 2}, []int{batchErr.Rejected[0].Index, batchErr.Rejected[1].Index})
+	mockRepository.AssertNotCalled(t, "CreateAll", mock.Anything)
+	assert.Equal(t, uint64(0), service.CatalogVersion())
+}
+
+func TestService_CreatePackage_AssignsIdsOnceWritten(t *testing.T) {
+	mockRepository := new(MockPackageRepository)
+	service := NewService(mockRepository)
+	mockRepository.On("CreateAll", mock.Anything).Return(errors.New("mock repository error")).Once()
+	mockRepository.On("CreateAll", mock.Anything).Return(nil).Once()
+	pkg := &domain.Package{Size: 10}
+
+	err := service.CreatePackage(pkg)
+	assert.Error(t, err)
+	assert.Empty(t, pkg.Id, "a failed write hands out no ID a retry would send")
+
+	err = service.CreatePackage(pkg)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pkg.Id)
+}
+
 func TestService_GetPackage(t *testing.T) {
 	testCases := []struct {
 		name           string
 		id             string
 		expectedResult *domain.Package
-		expectedFound  bool
+		expectedError  error
 		expectedCalled bool
 	}{
 		{
@@ -156,49 +163,41 @@ func TestService_GetPackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000001",
 				Size: 10,
 			},
-			expectedFound:  true,
 			expectedCalled: true,
 		},
 		{
 			name:           "Invalid UUID format",
 			id:             "invalid-uuid-format",
 			expectedResult: nil,
-			expectedFound:  false,
+			expectedError:  domain.ErrPackageNotFound,
 			expectedCalled: false,
 		},
 		{
 			name:           "Package not found",
 			id:             "00000000-0000-0000-0000-000000000002",
 			expectedResult: nil,
-			expectedFound:  false,
+			expectedError:  domain.ErrPackageNotFound,
 			expectedCalled: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			id, _ := uuid.Parse(tc.id)
-
 			mockRepository := new(MockPackageRepository)
 
 			service := NewService(mockRepository)
 
-			var expectedResult *inmemory.Package
-			if tc.expectedResult != nil {
-				expectedResult, _ = domainToStorage(tc.expectedResult)
-			}
-
-			mockRepository.On("Get", id).Return(expectedResult, tc.expectedFound)
+			mockRepository.On("Get", tc.id).Return(tc.expectedResult, tc.expectedError)
 
-			result, found := service.GetPackage(tc.id)
+			result, err := service.GetPackage(tc.id)
 
 			assert.Equal(t, tc.expectedResult, result)
-			assert.Equal(t, tc.expectedFound, found)
+			assert.ErrorIs(t, err, tc.expectedError)
 
 			if tc.expectedCalled {
 				mockRepository.AssertExpectations(t)
 			} else {
-				mockRepository.AssertNotCalled(t, "Get")
+				mockRepository.AssertNotCalled(t, "Get", tc.id)
 			}
 
 		})
@@ -216,8 +215,7 @@ func TestService_UpdatePackage(t *testing.T) {
 		name               string
 		id                 string
 		updatedPackage     *domain.Package
-		mockReturn         bool
-		expectedResult     bool
+		mockError          error
 		expectedError      error
 		expectedCalledOnce bool
 	}{
@@ -228,9 +226,7 @@ func TestService_UpdatePackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000002",
 				Size: 20,
 			},
-			mockReturn:         false,
-			expectedResult:     false,
-			expectedError:      errors.New("invalid UUID"),
+			expectedError:      domain.ErrPackageNotFound,
 			expectedCalledOnce: false,
 		},
 		{
@@ -240,9 +236,6 @@ func TestService_UpdatePackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000001",
 				Size: 10,
 			},
-			mockReturn:         true,
-			expectedResult:     true,
-			expectedError:      nil,
 			expectedCalledOnce: true,
 		},
 		{
@@ -252,9 +245,8 @@ func TestService_UpdatePackage(t *testing.T) {
 				Id:   "00000000-0000-0000-0000-000000000004",
 				Size: 20,
 			},
-			mockReturn:         false,
-			expectedResult:     false,
-			expectedError:      nil,
+			mockError:          domain.ErrDuplicatePackageSize,
+			expectedError:      domain.ErrDuplicatePackageSize,
 			expectedCalledOnce: true,
 		},
 	}
@@ -262,22 +254,19 @@ func TestService_UpdatePackage(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Prepare test data
-			id, _ := uuid.Parse(tc.id)
-			pkg, _ := domainToStorage(tc.updatedPackage)
-			mockRepository.On("Update", id, pkg).Return(tc.mockReturn)
+			mockRepository.On("Update", tc.id, tc.updatedPackage).Return(tc.mockError)
 
 			// Call the UpdatePackage function
-			result, err := service.UpdatePackage(tc.id, tc.updatedPackage)
+			err := service.UpdatePackage(tc.id, tc.updatedPackage)
 
-			// Assert the result and error
-			assert.Equal(t, tc.expectedResult, result)
-			assert.Equal(t, tc.expectedError, err)
+			// Assert the error
+			assert.ErrorIs(t, err, tc.expectedError)
 
 			// Verify that the Update method of the mock repository was called
 			if tc.expectedCalledOnce {
-				mockRepository.AssertCalled(t, "Update", id, mock.AnythingOfType("*inmemory.Package"))
+				mockRepository.AssertCalled(t, "Update", tc.id, mock.AnythingOfType("*domain.Package"))
 			} else {
-				mockRepository.AssertNotCalled(t, "Update", 0)
+				mockRepository.AssertNotCalled(t, "Update", tc.id, tc.updatedPackage)
 			}
 		})
 	}
@@ -289,34 +278,41 @@ func TestService_GetAllPackages(t *testing.T) {
 		return new(MockPackageRepository)
 	}
 
-	firstId, _ := uuid.Parse("00000000-0000-0000-0000-000000000001")
-	secondId, _ := uuid.Parse("00000000-0000-0000-0000-000000000002")
-	thirdId, _ := uuid.Parse("00000000-0000-0000-0000-000000000003")
+	firstId := "00000000-0000-0000-0000-000000000001"
+	secondId := "00000000-0000-0000-0000-000000000002"
+	thirdId := "00000000-0000-0000-0000-000000000003"
 
 	testCases := []struct {
 		name                 string
-		mockReturn           []*inmemory.Package
+		mockReturn           []*domain.Package
+		mockError            error
 		expectedResultLength int
-		expectedFirstID      *uuid.UUID
-		expectedSecondID     *uuid.UUID
+		expectedFirstID      string
+		expectedSecondID     string
+		expectedError        bool
 	}{
 		{
 			name:                 "Empty package retrieval",
-			mockReturn:           []*inmemory.Package{},
+			mockReturn:           []*domain.Package{},
 			expectedResultLength: 0,
-			expectedFirstID:      nil,
-			expectedSecondID:     nil,
 		},
 		{
 			name: "Valid package retrieval",
-			mockReturn: []*inmemory.Package{
-				{ID: firstId, Size: 10},
-				{ID: secondId, Size: 20},
-				{ID: thirdId, Size: 15},
+			mockReturn: []*domain.Package{
+				{Id: firstId, Size: 10},
+				{Id: secondId, Size: 20},
+				{Id: thirdId, Size: 15},
 			},
 			expectedResultLength: 3,
-			expectedFirstID:      &secondId,
-			expectedSecondID:     &thirdId,
+			expectedFirstID:      secondId,
+			expectedSecondID:     thirdId,
+		},
+		{
+			name:                 "Storage failure",
+			mockReturn:           []*domain.Package(nil),
+			mockError:            errors.New("connection refused"),
+			expectedResultLength: 0,
+			expectedError:        true,
 		},
 	}
 
@@ -325,16 +321,17 @@ func TestService_GetAllPackages(t *testing.T) {
 			mockRepository := newMockRepository()
 			service := NewService(mockRepository)
 
-			mockRepository.On("GetAllPackages").Return(tc.mockReturn)
+			mockRepository.On("GetAllPackages").Return(tc.mockReturn, tc.mockError)
 
-			result := service.GetAllPackages()
+			result, err := service.GetAllPackages()
 
+			assert.Equal(t, tc.expectedError, err != nil)
 			assert.Len(t, result, tc.expectedResultLength)
 			if len(result) > 0 {
-				assert.Equal(t, tc.expectedFirstID.String(), result[0].Id)
+				assert.Equal(t, tc.expectedFirstID, result[0].Id)
 			}
 			if len(result) > 1 {
-				assert.Equal(t, tc.expectedSecondID.String(), result[1].Id)
+				assert.Equal(t, tc.expectedSecondID, result[1].Id)
 			}
 
 			mockRepository.AssertCalled(t, "GetAllPackages")
@@ -342,33 +339,88 @@ func TestService_GetAllPackages(t *testing.T) {
 	}
 }
 
+func TestService_ReplaceCatalog(t *testing.T) {
+	existingId := "00000000-0000-0000-0000-000000000001"
+	mockRepository := new(MockPackageRepository)
+	service := NewService(mockRepository)
+	mockRepository.On("GetAllPackages").Return([]*domain.Package{
+		{Id: existingId, Size: 250, Stock: 3},
+		{Id: "00000000-0000-0000-0000-000000000002", Size: 500},
+	}, nil)
+	mockRepository.On("ReplaceAll", mock.Anything).Return(nil).Once()
+
+	kept := &domain.Package{Size: 250, Stock: 10}
+	added := &domain.Package{Sku: "bolts", Size: 250}
+	err := service.ReplaceCatalog(kept, added)
+
+	assert.NoError(t, err)
+	assert.Equal(t, existingId, kept.Id, "the package of the same size keeps its ID")
+	assert.NotEmpty(t, added.Id)
+	assert.NotEqual(t, existingId, added.Id, "sizes of other skus are new packages")
+	mockRepository.AssertCalled(t, "ReplaceAll", []*domain.Package{kept, added})
+	assert.Equal(t, uint64(1), service.CatalogVersion())
+
+	err = service.ReplaceCatalog(&domain.Package{Size: 250}, &domain.Package{Size: 250})
+	var batchErr *domain.BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize, "a stored size repeated in the catalog is a duplicate")
+	assert.Len(t, batchErr.Rejected, 1)
+	assert.Equal(t, 1, batchErr.Rejected[0].Index)
+	assert.Equal(t, uint64(1), service.CatalogVersion())
+
+	repositoryErr := &domain.BatchError{Rejected: []domain.RejectedPackage{{Index: 1, Err: domain.ErrDuplicatePackageSize}}}
+	mockRepository.On("ReplaceAll", mock.Anything).Return(repositoryErr)
+	reused := &domain.Package{Size: 250}
+	err = service.ReplaceCatalog(reused, &domain.Package{Size: 200})
+
+	assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+	assert.Empty(t, reused.Id, "a failed write hands out no ID a retry would send")
+	assert.Equal(t, uint64(1), service.CatalogVersion())
+}
+
+func TestService_PatchPackage(t *testing.T) {
+	id := "00000000-0000-0000-0000-000000000001"
+	stock := 3
+	patch := domain.PackagePatch{Stock: &stock}
+	mockRepository := new(MockPackageRepository)
+	service := NewService(mockRepository)
+	mockRepository.On("Patch", id, patch).Return(&domain.Package{Id: id, Size: 10, Stock: 3}, nil)
+
+	pkg, err := service.PatchPackage(id, patch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Package{Id: id, Size: 10, Stock: 3}, pkg)
+	assert.Equal(t, uint64(1), service.CatalogVersion())
+
+	_, err = service.PatchPackage("invalid-uuid-format", patch)
+	assert.ErrorIs(t, err, domain.ErrPackageNotFound)
+	mockRepository.AssertNumberOfCalls(t, "Patch", 1)
+}
+
 func TestService_DeletePackage(t *testing.T) {
 	testCases := []struct {
 		name               string
 		id                 string
-		mockReturn         bool
-		expectedResult     bool
+		mockError          error
+		expectedError      error
 		expectedCalledOnce bool
 	}{
 		{
 			name:               "Valid package deletion",
 			id:                 "00000000-0000-0000-0000-000000000001",
-			mockReturn:         true,
-			expectedResult:     true,
 			expectedCalledOnce: true,
 		},
 		{
 			name:               "Invalid UUID format",
 			id:                 "invalid-uuid-format",
-			mockReturn:         false,
-			expectedResult:     false,
+			expectedError:      domain.ErrPackageNotFound,
 			expectedCalledOnce: false,
 		},
 		{
 			name:               "Failed package deletion",
 			id:                 "00000000-0000-0000-0000-000000000002",
-			mockReturn:         false,
-			expectedResult:     false,
+			mockError:          domain.ErrPackageNotFound,
+			expectedError:      domain.ErrPackageNotFound,
 			expectedCalledOnce: true,
 		},
 	}
@@ -378,18 +430,49 @@ func TestService_DeletePackage(t *testing.T) {
 			mockRepository := new(MockPackageRepository)
 			service := NewService(mockRepository)
 
-			expectedUUID, _ := uuid.Parse(tc.id)
-			mockRepository.On("Delete", expectedUUID).Return(tc.mockReturn)
+			mockRepository.On("Delete", tc.id).Return(tc.mockError)
 
-			result := service.DeletePackage(tc.id)
+			err := service.DeletePackage(tc.id)
 
-			assert.Equal(t, tc.expectedResult, result)
+			assert.ErrorIs(t, err, tc.expectedError)
 
 			if tc.expectedCalledOnce {
-				mockRepository.AssertCalled(t, "Delete", expectedUUID)
+				mockRepository.AssertCalled(t, "Delete", tc.id)
 			} else {
-				mockRepository.AssertNotCalled(t, "Delete")
+				mockRepository.AssertNotCalled(t, "Delete", tc.id)
 			}
 		})
 	}
 }
+
+func TestService_CatalogVersion(t *testing.T) {
+	mockRepository := new(MockPackageRepository)
+	service := NewService(mockRepository)
+	existingId := "00000000-0000-0000-0000-000000000001"
+	missingId := "00000000-0000-0000-0000-000000000002"
+	mockRepository.On("CreateAll", mock.Anything).Return(nil)
+	mockRepository.On("Update", existingId, mock.Anything).Return(nil)
+	mockRepository.On("Update", missingId, mock.Anything).Return(domain.ErrPackageNotFound)
+	mockRepository.On("Delete", existingId).Return(nil)
+	mockRepository.On("Delete", missingId).Return(domain.ErrPackageNotFound)
+
+	assert.Equal(t, uint64(0), service.CatalogVersion())
+
+	service.CreatePackage(&domain.Package{Id: existingId, Size: 10}, &domain.Package{Size: 20})
+	assert.Equal(t, uint64(1), service.CatalogVersion(), "a batch is one write")
+
+	service.UpdatePackage(existingId, &domain.Package{Id: existingId, Size: 30})
+	service.UpdatePackage(missingId, &domain.Package{Id: missingId, Size: 30})
+	assert.Equal(t, uint64(2), service.CatalogVersion())
+
+	service.DeletePackage(existingId)
+	service.DeletePackage(missingId)
+	assert.Equal(t, uint64(3), service.CatalogVersion())
+
+	mockRepository.On("AdjustStock", map[string]int{existingId: -1}).Return(nil)
+	mockRepository.On("AdjustStock", map[string]int{missingId: -1}).Return(domain.ErrInsufficientStock)
+	service.AdjustStock(map[string]int{existingId: -1})
+	err := service.AdjustStock(map[string]int{missingId: -1})
+	assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+	assert.Equal(t, uint64(4), service.CatalogVersion())
+}
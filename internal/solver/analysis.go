@@ -0,0 +1,134 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+	"slices"
+)
+
+// Analysis describes what a catalog can ship.
+//
+// Only multiples of Gcd can be shipped exactly. Frobenius is the largest
+// amount that cannot, when Gcd is 1, and is -1 when every amount can; it is
+// nil otherwise, as infinitely many amounts cannot. DominatedSizes never
+// appear in a plan the objective ranks best. AverageOvershoot is the mean of
+// the least surplus shipped for each amount from From to To.
+type Analysis struct {
+	Sizes            []int
+	Gcd              int
+	Frobenius        *int
+	DominatedSizes   []int
+	From             int
+	To               int
+	AverageOvershoot float64
+}
+
+// Analyze analyses the problem's sizes, costs and objective over the amounts
+// from to to. Reachability and overshoot come from the catalog's periodic
+// table, see planTable, which covers all totals; sizes whose table exceeds
+// maxExactTotal fail with domain.ErrCatalogTooLarge.
+func Analyze(ctx context.Context, problem Problem, from, to int) (Analysis, error) {
+	sizes := problem.Sizes
+	if len(sizes) == 0 {
+		return Analysis{}, domain.ErrNoPackageSizes
+	}
+	unit := sizes[0]
+	for _, size := range sizes[1:] {
+		unit = gcd(unit, size)
+	}
+	table, err := periodicTable(ctx, sizes, unit)
+	if err != nil {
+		return Analysis{}, err
+	}
+	if table == nil {
+		return Analysis{}, domain.ErrCatalogTooLarge
+	}
+
+	analysis := Analysis{
+		Sizes:          sizes,
+		Gcd:            unit,
+		DominatedSizes: make([]int, 0),
+		From:           from,
+		To:             to,
+	}
+	if unit == 1 {
+		frobenius := -1
+		for total := len(table.packs) - 1; total > 0; total-- {
+			if table.packs[total] < 0 {
+				frobenius = total
+				break
+			}
+		}
+		analysis.Frobenius = &frobenius
+	}
+	for _, size := range sizes {
+		dominated, err := isDominated(ctx, problem, size)
+		if err != nil {
+			return Analysis{}, err
+		}
+		if dominated {
+			analysis.DominatedSizes = append(analysis.DominatedSizes, size)
+		}
+	}
+	if from <= to {
+		analysis.AverageOvershoot = table.overshoot(from, to) / float64(to-from+1)
+	}
+	return analysis, nil
+}
+
+// isDominated reports whether a plan without size beats a single pack of it
+// for an order of size items. Waste, packs and cost add up over the packs of
+// a plan, so such a plan also beats every plan with a pack of size once it
+// replaces that pack; otherwise the single pack is itself a best plan. The
+// argument does not hold for distinct_sizes, which only counts sizes once.
+func isDominated(ctx context.Context, problem Problem, size int) (bool, error) {
+	others := problem
+	others.Sizes = slices.DeleteFunc(slices.Clone(problem.Sizes), func(other int) bool { return other == size })
+	others.Amount = size
+	others.Alternatives, others.Stock, others.MaxWaste, others.ExactOnly = 0, nil, 0, false
+	if len(others.Sizes) == 0 {
+		return false, nil
+	}
+	best, err := ExactSolver{}.Solve(ctx, others)
+	if err != nil {
+		return false, err
+	}
+	single := domain.CandidatePackages{CurrentCombination: map[int]int{size: 1}}
+	return problem.Objective.compare(keyOf(others, best[0]), keyOf(others, single)) < 0, nil
+}
+
+// overshoot totals the surplus items shipped for the amounts from to to. An
+// amount is shipped as the first reachable total at or past it; past the
+// bound every total is reachable and only the rounding up to a whole unit is
+// shipped in surplus.
+func (t *planTable) overshoot(from, to int) float64 {
+	// next[u] is the first reachable total from u units on
+	next := slices.Clone(t.packs[:t.bound+2])
+	reachable := len(t.packs)
+	for total := len(next) - 1; total >= 0; total-- {
+		if t.packs[total] >= 0 {
+			reachable = total
+		}
+		next[total] = int32(reachable)
+	}
+
+	sum := 0.0
+	amount := max(from, 1)
+	// the amounts of (u-1)*unit+1 to u*unit items all round up to u units
+	for units := (amount + t.unit - 1) / t.unit; amount <= to && units < len(next); units++ {
+		last := min(to, units*t.unit)
+		count := float64(last - amount + 1)
+		sum += count*float64(int(next[units])*t.unit) - count*(float64(amount)+float64(last))/2
+		if last == to {
+			return sum
+		}
+		amount = last + 1
+	}
+	// past the bound the surplus (-amount) mod unit repeats every unit items
+	remaining := to - amount + 1
+	sum += float64(remaining/t.unit) * float64(t.unit*(t.unit-1)) / 2
+	for i := 0; i < remaining%t.unit; i++ {
+		sum += float64((t.unit - (to-i)%t.unit) % t.unit)
+	}
+	return sum
+}
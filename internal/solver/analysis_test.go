@@ -0,0 +1,83 @@
+package solver
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	frobenius := func(n int) *int { return &n }
+	minimizeCost, _ := ParseObjective(MinimizeCost)
+
+	testCases := []struct {
+		name          string
+		problem       Problem
+		from, to      int
+		expected      Analysis
+		expectedError error
+	}{
+		{
+			name:    "Multiples of the gcd",
+			problem: Problem{Sizes: []int{1000, 500, 250}},
+			from:    1, to: 1000,
+			// every amount rounds up to the next multiple of 250
+			expected: Analysis{Sizes: []int{1000, 500, 250}, Gcd: 250, DominatedSizes: []int{}, From: 1, To: 1000, AverageOvershoot: 124.5},
+		},
+		{
+			name:    "Frobenius number",
+			problem: Problem{Sizes: []int{20, 9, 6}},
+			from:    44, to: 100,
+			expected: Analysis{Sizes: []int{20, 9, 6}, Gcd: 1, Frobenius: frobenius(43), DominatedSizes: []int{}, From: 44, To: 100},
+		},
+		{
+			name:    "Every amount",
+			problem: Problem{Sizes: []int{3, 1}},
+			from:    1, to: 10,
+			expected: Analysis{Sizes: []int{3, 1}, Gcd: 1, Frobenius: frobenius(-1), DominatedSizes: []int{}, From: 1, To: 10},
+		},
+		{
+			name:    "Unreachable amounts",
+			problem: Problem{Sizes: []int{5, 3}},
+			from:    1, to: 8,
+			// 1 and 2 ship 3, 4 ships 5 and 7 ships 8: 5 surplus items over 8 amounts
+			expected: Analysis{Sizes: []int{5, 3}, Gcd: 1, Frobenius: frobenius(7), DominatedSizes: []int{}, From: 1, To: 8, AverageOvershoot: 0.625},
+		},
+		{
+			name: "Dominated by cheaper packs",
+			problem: Problem{
+				Sizes:     []int{1000, 500, 250},
+				Costs:     map[int]float64{1000: 3, 500: 2.5, 250: 1},
+				Objective: minimizeCost,
+			},
+			from: 1, to: 0,
+			expected: Analysis{Sizes: []int{1000, 500, 250}, Gcd: 250, DominatedSizes: []int{500}, From: 1, To: 0},
+		},
+		{
+			name:          "No package sizes",
+			problem:       Problem{},
+			expectedError: domain.ErrNoPackageSizes,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analysis, err := Analyze(context.Background(), tc.problem, tc.from, tc.to)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, analysis)
+		})
+	}
+
+	t.Run("Huge range", func(t *testing.T) {
+		analysis, err := Analyze(context.Background(), Problem{Sizes: []int{500, 250}}, 1, 1<<50)
+
+		assert.NoError(t, err)
+		assert.InDelta(t, 124.5, analysis.AverageOvershoot, 1e-6)
+	})
+}
@@ -0,0 +1,198 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+)
+
+// BranchAndBoundSolver searches pack counts largest size first and prunes any
+// branch whose lower bound under the objective cannot beat the plans found
+// so far. It is exact and uses no memory proportional to the amount, but its
+// running time can grow quickly for large amounts with many sizes.
+type BranchAndBoundSolver struct{}
+
+func (BranchAndBoundSolver) Solve(ctx context.Context, problem Problem) ([]domain.CandidatePackages, error) {
+	if err := problem.check(); err != nil {
+		return nil, err
+	}
+	return newBranchAndBound(ctx, problem, gcdBound(problem.Sizes)).run()
+}
+
+// wasteBound returns a lower bound on the overshoot of any plan covering
+// remaining items with sizes[index:].
+type wasteBound func(index, remaining int) int
+
+// gcdBound relies on every total reachable with sizes[i:] being a multiple of
+// their gcd.
+func gcdBound(sizes []int) wasteBound {
+	suffixGcd := make([]int, len(sizes))
+	suffixGcd[len(sizes)-1] = sizes[len(sizes)-1]
+	for i := len(sizes) - 2; i >= 0; i-- {
+		suffixGcd[i] = gcd(sizes[i], suffixGcd[i+1])
+	}
+	return func(index, remaining int) int {
+		step := suffixGcd[index]
+		return (remaining+step-1)/step*step - remaining
+	}
+}
+
+// branchAndBound keeps the best plans found so far, best first. Counts only
+// ever complete a plan with the pack that crosses the amount, so every plan
+// it records is minimal: dropping any pack would leave the order short. The
+// search stops early, with err set, once ctx is done.
+type branchAndBound struct {
+	ctx       context.Context
+	err       error
+	steps     int
+	amount    int
+	sizes     []int
+	stock     []int
+	costs     []float64
+	costRate  []float64
+	objective Objective
+	maxWaste  int
+	keep      int
+	minWaste  wasteBound
+	counts    []int
+	plans     []rankedPlan
+}
+
+type rankedPlan struct {
+	counts []int
+	key    planKey
+}
+
+func newBranchAndBound(ctx context.Context, problem Problem, minWaste wasteBound) *branchAndBound {
+	stock := make([]int, len(problem.Sizes))
+	costs := make([]float64, len(problem.Sizes))
+	for i, size := range problem.Sizes {
+		stock[i] = problem.available(size)
+		costs[i] = problem.Costs[size]
+	}
+
+	// costRate[i] is the cheapest cost per item among sizes[i:], so covering
+	// n more items with them costs at least n*costRate[i].
+	costRate := make([]float64, len(problem.Sizes))
+	for i := len(problem.Sizes) - 1; i >= 0; i-- {
+		costRate[i] = costs[i] / float64(problem.Sizes[i])
+		if i+1 < len(problem.Sizes) {
+			costRate[i] = min(costRate[i], costRate[i+1])
+		}
+	}
+
+	return &branchAndBound{
+		ctx:       ctx,
+		amount:    problem.Amount,
+		sizes:     problem.Sizes,
+		stock:     stock,
+		costs:     costs,
+		costRate:  costRate,
+		objective: problem.Objective,
+		maxWaste:  problem.wasteLimit(),
+		keep:      1 + max(problem.Alternatives, 0),
+		minWaste:  minWaste,
+		counts:    make([]int, len(problem.Sizes)),
+	}
+}
+
+func (b *branchAndBound) run() ([]domain.CandidatePackages, error) {
+	if b.amount <= 0 {
+		return []domain.CandidatePackages{newCandidate()}, nil
+	}
+	b.branch(0, b.amount, planKey{})
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.plans) == 0 {
+		return nil, domain.ErrAmountUnreachable
+	}
+
+	candidates := make([]domain.CandidatePackages, 0, len(b.plans))
+	for _, plan := range b.plans {
+		candidate := newCandidate()
+		for i, count := range plan.counts {
+			if count > 0 {
+				candidate.CurrentCombination[b.sizes[i]] = count
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// branch extends the partial plan whose packs and cost so far are in used.
+func (b *branchAndBound) branch(index, remaining int, used planKey) {
+	if b.steps%checkInterval == 0 && b.err == nil {
+		b.err = b.ctx.Err()
+	}
+	b.steps++
+	if b.err != nil {
+		return
+	}
+	if remaining <= 0 {
+		used.waste = -remaining
+		b.record(used)
+		return
+	}
+	if index == len(b.sizes) {
+		return
+	}
+
+	size := b.sizes[index]
+	bound := planKey{
+		waste: b.minWaste(index, remaining),
+		packs: used.packs + (remaining+size-1)/size,
+		cost:  used.cost + float64(remaining)*b.costRate[index],
+		sizes: used.sizes + 1,
+	}
+	if bound.waste > b.maxWaste {
+		return
+	}
+	if b.full() && b.objective.compare(b.worst().key, bound) <= 0 {
+		return
+	}
+
+	for count := min((remaining+size-1)/size, b.stock[index]); count >= 0; count-- {
+		b.counts[index] = count
+		next := planKey{
+			packs: used.packs + count,
+			cost:  used.cost + float64(count)*b.costs[index],
+			sizes: used.sizes,
+		}
+		if count > 0 {
+			next.sizes++
+		}
+		b.branch(index+1, remaining-count*size, next)
+	}
+	b.counts[index] = 0
+}
+
+func (b *branchAndBound) full() bool {
+	return len(b.plans) == b.keep
+}
+
+func (b *branchAndBound) worst() rankedPlan {
+	return b.plans[len(b.plans)-1]
+}
+
+// record inserts the current counts after any plan ranked at least as well,
+// dropping the worst plan once more than keep are held.
+func (b *branchAndBound) record(key planKey) {
+	if key.waste > b.maxWaste {
+		return
+	}
+	if b.full() && b.objective.compare(b.worst().key, key) <= 0 {
+		return
+	}
+	position := len(b.plans)
+	for position > 0 && b.objective.compare(b.plans[position-1].key, key) > 0 {
+		position--
+	}
+	plan := rankedPlan{counts: append([]int(nil), b.counts...), key: key}
+	b.plans = append(b.plans, rankedPlan{})
+	copy(b.plans[position+1:], b.plans[position:])
+	b.plans[position] = plan
+	if len(b.plans) > b.keep {
+		b.plans = b.plans[:b.keep]
+	}
+}
@@ -0,0 +1,116 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+)
+
+// maxExactTotal caps the tables, in gcd units, at roughly 160MB so a single
+// huge order cannot exhaust the server's memory. The tables built for single
+// orders share a budget of that many entries, see tableBudget.
+const maxExactTotal = 20_000_000
+
+// ExactSolver always returns the optimal plan under the objective. For the
+// default one, least overshoot then fewest packs, it looks the order up in a
+// table of the fewest packs reaching every total in gcd units: a plan
+// shipping amount+largest or more always contains a pack that can be dropped
+// while still covering the order, so the optimum lies within one largest pack
+// of the amount. Optimal plans become periodic past a bound depending only on
+// the sizes, so the table is built once per catalog and answers any amount,
+// see planTable; when that table is too large, orders past the largest size
+// are answered from the residues modulo it, see residueTable. Alternatives,
+// other objectives and stock-limited problems are solved by branch and bound
+// over the totals up to amount+largest-1, capped at maxExactTotal, using the
+// totals reachable within stock to bound the overshoot of each branch.
+type ExactSolver struct{}
+
+func (ExactSolver) Solve(ctx context.Context, problem Problem) ([]domain.CandidatePackages, error) {
+	if err := problem.check(); err != nil {
+		return nil, err
+	}
+	sizes := problem.Sizes
+	if problem.Amount <= 0 {
+		return []domain.CandidatePackages{newCandidate()}, nil
+	}
+	// the plan ships up to amount+largest-1 items
+	if problem.Amount > math.MaxInt-sizes[0] {
+		return nil, domain.ErrAmountTooLarge
+	}
+
+	// Every reachable total is a multiple of the sizes' gcd, so the tables are
+	// kept in gcd units to shrink them.
+	unit := sizes[0]
+	for _, size := range sizes[1:] {
+		unit = gcd(unit, size)
+	}
+	target := (problem.Amount + unit - 1) / unit
+
+	if problem.Alternatives > 0 || problem.Stock != nil || !problem.Objective.followsRules() {
+		if target > maxExactTotal-sizes[0]/unit {
+			return nil, domain.ErrAmountTooLarge
+		}
+		limit := target + sizes[0]/unit - 1
+		bound, err := reachableBound(ctx, problem, unit, target, limit)
+		if err != nil {
+			return nil, err
+		}
+		return newBranchAndBound(ctx, problem, bound).run()
+	}
+
+	table, err := rulesTable(ctx, sizes, unit, target)
+	if err != nil {
+		return nil, err
+	}
+	return table.solve(problem)
+}
+
+// reachableBound bounds the overshoot by the distance to the next total that
+// any combination of the packs in stock can reach, which is never further
+// than the next total reachable with a subset of them.
+func reachableBound(ctx context.Context, problem Problem, unit, target, limit int) (wasteBound, error) {
+	if err := tableBudget.acquire(ctx, limit+1); err != nil {
+		return nil, err
+	}
+	defer tableBudget.release(limit + 1)
+	// used[t] counts the packs of the current size needed to first reach t,
+	// so no size is used beyond its stock.
+	reachable := make([]bool, limit+1)
+	used := make([]int32, limit+1)
+	reachable[0] = true
+	for _, size := range problem.Sizes {
+		step := size / unit
+		available := problem.available(size)
+		clear(used)
+		for total := step; total <= limit; total++ {
+			if total%checkInterval == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !reachable[total] && reachable[total-step] && int(used[total-step]) < available {
+				reachable[total] = true
+				used[total] = used[total-step] + 1
+			}
+		}
+	}
+
+	// next[t] is the smallest reachable total >= t, for t up to the target.
+	next := make([]int32, target+1)
+	following := int32(-1)
+	for total := limit; total >= 0; total-- {
+		if reachable[total] {
+			following = int32(total)
+		}
+		if total <= target {
+			next[total] = following
+		}
+	}
+
+	fallback := gcdBound(problem.Sizes)
+	return func(index, remaining int) int {
+		bound := fallback(index, remaining)
+		if following := next[(remaining+unit-1)/unit]; following >= 0 {
+			bound = max(bound, int(following)*unit-remaining)
+		}
+		return bound
+	}, nil
+}
@@ -0,0 +1,190 @@
+package solver
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestExactSolver_Solve(t *testing.T) {
+	testCases := []struct {
+		name             string
+		sizes            []int
+		amount           int
+		expectedWaste    int
+		expectedPackages int
+	}{
+		{name: "Single item", sizes: []int{5000, 2000, 1000, 500, 250}, amount: 1, expectedWaste: 249, expectedPackages: 1},
+		{name: "Exact pack", sizes: []int{5000, 2000, 1000, 500, 250}, amount: 250, expectedWaste: 0, expectedPackages: 1},
+		{name: "Prefer one bigger pack", sizes: []int{5000, 2000, 1000, 500, 250}, amount: 251, expectedWaste: 249, expectedPackages: 1},
+		{name: "Mixed packs", sizes: []int{5000, 2000, 1000, 500, 250}, amount: 12001, expectedWaste: 249, expectedPackages: 4},
+		{name: "Awkward sizes with large amount", sizes: []int{53, 31, 23}, amount: 500000, expectedWaste: 0, expectedPackages: 9438},
+		{name: "Sizes sharing a divisor", sizes: []int{15, 9, 6}, amount: 16, expectedWaste: 2, expectedPackages: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			candidates, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: tc.sizes, Amount: tc.amount})
+
+			assert.NoError(t, err)
+			assert.Len(t, candidates, 1)
+			best := candidates[0]
+			assert.Equal(t, tc.expectedWaste, best.Waste(tc.amount))
+			assert.Equal(t, tc.expectedPackages, best.NumberOfPackages())
+		})
+	}
+
+	t.Run("Matches exhaustive search", func(t *testing.T) {
+		sizes := []int{13, 11, 7}
+		for amount := 1; amount <= 200; amount++ {
+			candidates, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: sizes, Amount: amount})
+			assert.NoError(t, err)
+			best := candidates[0]
+
+			for a := 0; a*sizes[0] < amount+sizes[0]; a++ {
+				for b := 0; b*sizes[1] < amount+sizes[1]; b++ {
+					for c := 0; c*sizes[2] < amount+sizes[2]; c++ {
+						if a*sizes[0]+b*sizes[1]+c*sizes[2] < amount {
+							continue
+						}
+						candidate := domain.CandidatePackages{CurrentCombination: map[int]int{sizes[0]: a, sizes[1]: b, sizes[2]: c}}
+						assert.LessOrEqual(t, best.Compare(candidate, amount), 0, "amount %d: %v beats %v", amount, candidate, best)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("No package sizes", func(t *testing.T) {
+		_, err := ExactSolver{}.Solve(context.Background(), Problem{Amount: 10})
+
+		assert.ErrorIs(t, err, domain.ErrNoPackageSizes)
+	})
+
+	t.Run("Huge amounts", func(t *testing.T) {
+		candidates, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{53, 31, 23}, Amount: 1 << 40})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, candidates[0].Waste(1<<40))
+
+		amount := math.MaxInt - 5000
+		candidates, err = ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{5000, 2000, 1000, 500, 250}, Amount: amount})
+		assert.NoError(t, err)
+		// the last 807 items ship in a pack of 1000
+		assert.Equal(t, 193, candidates[0].Waste(amount))
+		assert.Equal(t, amount/5000+1, candidates[0].NumberOfPackages())
+
+		// the periodic table of these sizes would not fit in memory, so the
+		// order is answered from the residues modulo the largest size
+		candidates, err = ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{99991, 99989}, Amount: 1 << 40})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, candidates[0].Waste(1<<40))
+		smaller := 0
+		for (1<<40-smaller*99989)%99991 != 0 {
+			smaller++
+		}
+		assert.Equal(t, map[int]int{99991: (1<<40 - smaller*99989) / 99991, 99989: smaller}, candidates[0].CurrentCombination)
+	})
+
+	t.Run("Small orders of large coprime sizes", func(t *testing.T) {
+		candidates, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{4999, 3001}, Amount: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2991, candidates[0].Waste(10))
+
+		// a pack beyond maxExactTotal does not rule out small orders
+		candidates, err = ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{30_000_001, 3}, Amount: 5})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, candidates[0].Waste(5))
+		assert.Equal(t, 2, candidates[0].NumberOfPackages())
+	})
+
+	t.Run("Amount too large", func(t *testing.T) {
+		// neither the periodic table nor the residues of these sizes fit in
+		// memory
+		_, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{30_000_001, 3}, Amount: 1 << 40})
+		assert.ErrorIs(t, err, domain.ErrAmountTooLarge)
+
+		_, err = ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{53, 31, 23}, Amount: math.MaxInt - 10})
+		assert.ErrorIs(t, err, domain.ErrAmountTooLarge)
+	})
+}
+
+func TestRulesTable_SmallOrders(t *testing.T) {
+	// the periodic table of these sizes is too large to memoize, so small
+	// orders get a table of their own
+	table, err := rulesTable(context.Background(), []int{4999, 3001}, 1, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, table.(*planTable).packs, 10+3001)
+}
+
+func TestResidueTable(t *testing.T) {
+	for _, sizes := range [][]int{{13, 11, 7}, {53, 31, 23}, {15, 9, 6}, {20, 12}} {
+		unit := sizes[0]
+		for _, size := range sizes[1:] {
+			unit = gcd(unit, size)
+		}
+		residues, err := newResidueTable(context.Background(), sizes, unit)
+		assert.NoError(t, err)
+		periodic, _ := periodicTable(context.Background(), sizes, unit)
+
+		covered := 0
+		for amount := 1; amount <= 5000; amount++ {
+			if !residues.covers((amount + unit - 1) / unit) {
+				continue
+			}
+			covered++
+			problem := Problem{Sizes: sizes, Amount: amount}
+			expected, _ := periodic.solve(problem)
+			actual, err := residues.solve(problem)
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected[0].Waste(amount), actual[0].Waste(amount), "sizes %v, amount %d", sizes, amount)
+			assert.Equal(t, expected[0].NumberOfPackages(), actual[0].NumberOfPackages(), "sizes %v, amount %d", sizes, amount)
+		}
+		assert.Greater(t, covered, 4000, "sizes %v", sizes)
+	}
+}
+
+func TestPlanTable_Periodic(t *testing.T) {
+	for _, sizes := range [][]int{{13, 11, 7}, {53, 31, 23}, {15, 9, 6}, {9}} {
+		unit := sizes[0]
+		for _, size := range sizes[1:] {
+			unit = gcd(unit, size)
+		}
+		periodic, err := rulesTable(context.Background(), sizes, unit, 1)
+		assert.NoError(t, err)
+
+		for amount := 1; amount <= 5000; amount++ {
+			target := (amount + unit - 1) / unit
+			problem := Problem{Sizes: sizes, Amount: amount}
+			table, _ := newPlanTable(context.Background(), sizes, unit, target+sizes[0]/unit, math.MaxInt)
+			expected, _ := table.solve(problem)
+			actual, err := periodic.solve(problem)
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected[0].Waste(amount), actual[0].Waste(amount), "sizes %v, amount %d", sizes, amount)
+			assert.Equal(t, expected[0].NumberOfPackages(), actual[0].NumberOfPackages(), "sizes %v, amount %d", sizes, amount)
+		}
+	}
+}
+
+func TestPeriodicTable_SharedByConcurrentOrders(t *testing.T) {
+	sizes := []int{997, 991, 983}
+	tables := make([]*planTable, 8)
+	var wg sync.WaitGroup
+	for i := range tables {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tables[i], _ = periodicTable(context.Background(), sizes, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, table := range tables {
+		assert.Same(t, tables[0], table, "the table is built once")
+	}
+}
@@ -0,0 +1,50 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+)
+
+// GreedySolver fills the order with as many of each size as fit, largest
+// first, and covers what is left with the smallest pack that holds it, or
+// with the largest packs left in stock when none does. It runs in time
+// linear in the number of sizes but may overshoot or use more packs than
+// necessary, and fails when its plan exceeds the waste limit. It never
+// offers alternatives.
+type GreedySolver struct{}
+
+func (GreedySolver) Solve(_ context.Context, problem Problem) ([]domain.CandidatePackages, error) {
+	if err := problem.check(); err != nil {
+		return nil, err
+	}
+	best := newCandidate()
+	sizes := problem.Sizes
+	left := func(size int) int {
+		return problem.available(size) - best.CurrentCombination[size]
+	}
+
+	remaining := problem.Amount
+	for _, size := range sizes {
+		if remaining <= 0 {
+			break
+		}
+		if count := min(remaining/size, left(size)); count > 0 {
+			best.CurrentCombination[size] += count
+			remaining -= count * size
+		}
+	}
+	for remaining > 0 {
+		cover := 0
+		for _, size := range sizes {
+			if left(size) > 0 && (cover == 0 || size >= remaining) {
+				cover = size
+			}
+		}
+		best.CurrentCombination[cover]++
+		remaining -= cover
+	}
+	if -remaining > problem.wasteLimit() {
+		return nil, domain.ErrAmountUnreachable
+	}
+	return []domain.CandidatePackages{best}, nil
+}
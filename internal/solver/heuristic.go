@@ -0,0 +1,46 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+	"maps"
+)
+
+// heuristicWindow is how many largest packs' worth of the order is left for
+// the exact solver once the rest has been filled with largest packs.
+const heuristicWindow = 100
+
+// HeuristicSolver is meant for huge amounts: it ships largest packs until the
+// remainder fits in a fixed window and solves only that remainder exactly, so
+// its cost does not depend on the amount. The plan is usually, but not
+// always, optimal.
+type HeuristicSolver struct{}
+
+func (HeuristicSolver) Solve(ctx context.Context, problem Problem) ([]domain.CandidatePackages, error) {
+	if err := problem.check(); err != nil {
+		return nil, err
+	}
+
+	largest := problem.Sizes[0]
+	filled := 0
+	if window := heuristicWindow * largest; problem.Amount > window {
+		filled = min((problem.Amount-window)/largest, problem.available(largest))
+	}
+
+	remainder := problem
+	remainder.Amount -= filled * largest
+	if problem.Stock != nil {
+		remainder.Stock = maps.Clone(problem.Stock)
+		remainder.Stock[largest] -= filled
+	}
+	candidates, err := ExactSolver{}.Solve(ctx, remainder)
+	if err != nil {
+		return nil, err
+	}
+	if filled > 0 {
+		for _, candidate := range candidates {
+			candidate.CurrentCombination[largest] += filled
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,214 @@
+package solver
+
+import (
+	"cmp"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Criterion is a measure of a plan that objectives minimise.
+type Criterion string
+
+const (
+	// Waste is the number of surplus items shipped.
+	Waste Criterion = "waste"
+	// Packs is the number of packs shipped.
+	Packs Criterion = "packs"
+	// Cost is the total unit cost of the packs shipped.
+	Cost Criterion = "cost"
+	// DistinctSizes is the number of different pack sizes shipped.
+	DistinctSizes Criterion = "distinct_sizes"
+	// WeightedScore is reported by Decide when the weighted sum of a weighted
+	// objective separates two plans; it cannot be used in objectives.
+	WeightedScore Criterion = "weighted_score"
+)
+
+// Preset objective names accepted by ParseObjective.
+const (
+	MinimizeWaste = "minimize_waste"
+	MinimizeCost  = "minimize_cost"
+	MinimizePacks = "minimize_packs"
+)
+
+// ruleCriteria are the README rules: least overshoot (Rule 2), then fewest
+// packs (Rule 3). Every objective falls back to them to break ties.
+var ruleCriteria = []Criterion{Waste, Packs}
+
+var presets = map[string]Objective{
+	MinimizeWaste: {Criteria: ruleCriteria},
+	MinimizeCost:  {Criteria: []Criterion{Cost, Waste, Packs}},
+	MinimizePacks: {Criteria: []Criterion{Packs, Waste}},
+}
+
+// Objective ranks plans. Without weights the criteria are compared in order,
+// each one only breaking ties of the previous; with weights every plan is
+// scored by the weighted sum of its criteria. The zero value follows the
+// README rules.
+type Objective struct {
+	Criteria []Criterion
+	Weights  map[Criterion]float64
+}
+
+// ParseObjective reads an objective definition: a preset name, criteria in
+// priority order such as "packs,waste", or weights such as
+// "waste:1,packs:250". An empty definition is the default objective.
+func ParseObjective(definition string) (Objective, error) {
+	definition = strings.TrimSpace(definition)
+	if definition == "" {
+		return Objective{}, nil
+	}
+	if preset, ok := presets[definition]; ok {
+		return preset, nil
+	}
+
+	var objective Objective
+	for _, field := range strings.Split(definition, ",") {
+		name, weight, weighted := strings.Cut(strings.TrimSpace(field), ":")
+		criterion := Criterion(strings.TrimSpace(name))
+		if !slices.Contains([]Criterion{Waste, Packs, Cost, DistinctSizes}, criterion) {
+			return Objective{}, fmt.Errorf("unknown objective criterion %q", name)
+		}
+		if slices.Contains(objective.Criteria, criterion) {
+			return Objective{}, fmt.Errorf("objective criterion %q repeated", name)
+		}
+		if weighted != (objective.Weights != nil) && len(objective.Criteria) > 0 {
+			return Objective{}, fmt.Errorf("objective %q mixes weighted and ordered criteria", definition)
+		}
+		objective.Criteria = append(objective.Criteria, criterion)
+		if !weighted {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || value < 0 {
+			return Objective{}, fmt.Errorf("objective weight %q must be a non-negative number", weight)
+		}
+		if objective.Weights == nil {
+			objective.Weights = make(map[Criterion]float64)
+		}
+		objective.Weights[criterion] = value
+	}
+	return objective, nil
+}
+
+// PresetNames lists the preset objectives in alphabetical order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// String formats the objective the way ParseObjective reads it.
+func (o Objective) String() string {
+	criteria := o.Criteria
+	if len(criteria) == 0 {
+		criteria = ruleCriteria
+	}
+	fields := make([]string, 0, len(criteria))
+	for _, criterion := range criteria {
+		if o.Weights != nil {
+			fields = append(fields, string(criterion)+":"+strconv.FormatFloat(o.Weights[criterion], 'g', -1, 64))
+		} else {
+			fields = append(fields, string(criterion))
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Decide names the criterion on which winner ranks ahead of loser for the
+// problem, falling back to the README rules, or returns an empty criterion
+// when winner does not rank ahead.
+func (o Objective) Decide(problem Problem, winner, loser domain.CandidatePackages) Criterion {
+	criterion, n := o.decide(keyOf(problem, winner), keyOf(problem, loser))
+	if n >= 0 {
+		return ""
+	}
+	return criterion
+}
+
+// followsRules reports whether the objective ranks exactly like the README
+// rules, which the exact solver's dynamic programme is specialised for.
+func (o Objective) followsRules() bool {
+	return o.Weights == nil && len(o.Criteria) <= len(ruleCriteria) &&
+		slices.Equal(o.Criteria, ruleCriteria[:len(o.Criteria)])
+}
+
+// planKey holds what the objectives rank a plan on.
+type planKey struct {
+	waste int
+	packs int
+	cost  float64
+	sizes int
+}
+
+func keyOf(problem Problem, candidate domain.CandidatePackages) planKey {
+	sizes := 0
+	for _, count := range candidate.CurrentCombination {
+		if count > 0 {
+			sizes++
+		}
+	}
+	return planKey{
+		waste: candidate.Waste(problem.Amount),
+		packs: candidate.NumberOfPackages(),
+		cost:  candidate.Cost(problem.Costs),
+		sizes: sizes,
+	}
+}
+
+func (k planKey) value(criterion Criterion) float64 {
+	switch criterion {
+	case Waste:
+		return float64(k.waste)
+	case Packs:
+		return float64(k.packs)
+	case Cost:
+		return k.cost
+	case DistinctSizes:
+		return float64(k.sizes)
+	default:
+		return 0
+	}
+}
+
+// compare orders two plan keys under the objective; negative means x is the
+// better plan. Every criterion of a lower bound key must be no greater than
+// that of the plans it bounds, so comparing against it stays admissible.
+func (o Objective) compare(x, y planKey) int {
+	_, n := o.decide(x, y)
+	return n
+}
+
+// decide compares two plan keys like compare and names the criterion that
+// separated them.
+func (o Objective) decide(x, y planKey) (Criterion, int) {
+	if o.Weights != nil {
+		score := func(k planKey) float64 {
+			sum := 0.0
+			for _, criterion := range o.Criteria {
+				sum += o.Weights[criterion] * k.value(criterion)
+			}
+			return sum
+		}
+		if n := cmp.Compare(score(x), score(y)); n != 0 {
+			return WeightedScore, n
+		}
+	} else {
+		for _, criterion := range o.Criteria {
+			if n := cmp.Compare(x.value(criterion), y.value(criterion)); n != 0 {
+				return criterion, n
+			}
+		}
+	}
+	for _, criterion := range ruleCriteria {
+		if n := cmp.Compare(x.value(criterion), y.value(criterion)); n != 0 {
+			return criterion, n
+		}
+	}
+	return "", 0
+}
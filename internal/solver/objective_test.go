@@ -0,0 +1,83 @@
+package solver
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"testing"
+)
+
+func TestParseObjective(t *testing.T) {
+	testCases := []struct {
+		name          string
+		definition    string
+		expected      Objective
+		expectedError string
+	}{
+		{name: "Empty", definition: "", expected: Objective{}},
+		{name: "Preset", definition: MinimizeCost, expected: Objective{Criteria: []Criterion{Cost, Waste, Packs}}},
+		{name: "Ordered", definition: "packs, distinct_sizes", expected: Objective{Criteria: []Criterion{Packs, DistinctSizes}}},
+		{
+			name:       "Weighted",
+			definition: "waste:1,packs:250",
+			expected:   Objective{Criteria: []Criterion{Waste, Packs}, Weights: map[Criterion]float64{Waste: 1, Packs: 250}},
+		},
+		{name: "Unknown criterion", definition: "speed", expectedError: `unknown objective criterion "speed"`},
+		{name: "Repeated criterion", definition: "waste,waste", expectedError: `objective criterion "waste" repeated`},
+		{name: "Mixed", definition: "waste:1,packs", expectedError: `objective "waste:1,packs" mixes weighted and ordered criteria`},
+		{name: "Negative weight", definition: "waste:-1", expectedError: `objective weight "-1" must be a non-negative number`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objective, err := ParseObjective(tc.definition)
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, objective)
+		})
+	}
+}
+
+func TestObjective_Compare(t *testing.T) {
+	fewerPacks := planKey{waste: 10, packs: 1, cost: 3, sizes: 1}
+	lessWaste := planKey{waste: 0, packs: 3, cost: 2, sizes: 2}
+
+	rules, _ := ParseObjective(MinimizeWaste)
+	packs, _ := ParseObjective(MinimizePacks)
+	weighted, _ := ParseObjective("waste:1,packs:20")
+
+	assert.Positive(t, rules.compare(fewerPacks, lessWaste))
+	assert.Negative(t, packs.compare(fewerPacks, lessWaste))
+	assert.Negative(t, weighted.compare(fewerPacks, lessWaste))
+	assert.True(t, rules.followsRules())
+	assert.True(t, Objective{}.followsRules())
+	assert.False(t, packs.followsRules())
+}
+
+func TestObjective_String(t *testing.T) {
+	for _, definition := range []string{"waste,packs", "cost,waste,packs", "packs,distinct_sizes", "waste:1,packs:250", "cost:0.5"} {
+		objective, _ := ParseObjective(definition)
+
+		assert.Equal(t, definition, objective.String())
+	}
+	assert.Equal(t, "waste,packs", Objective{}.String())
+}
+
+func TestObjective_Decide(t *testing.T) {
+	problem := Problem{Sizes: []int{5, 3}, Amount: 9, Costs: map[int]float64{5: 3, 3: 1}}
+	twoFives := domain.CandidatePackages{CurrentCombination: map[int]int{5: 2}}
+	threeThrees := domain.CandidatePackages{CurrentCombination: map[int]int{3: 3}}
+
+	rules, _ := ParseObjective(MinimizeWaste)
+	packs, _ := ParseObjective(MinimizePacks)
+	weighted, _ := ParseObjective("waste:1,packs:20")
+
+	assert.Equal(t, Waste, rules.Decide(problem, threeThrees, twoFives))
+	assert.Equal(t, Criterion(""), rules.Decide(problem, twoFives, threeThrees))
+	assert.Equal(t, Packs, packs.Decide(problem, twoFives, threeThrees))
+	assert.Equal(t, WeightedScore, weighted.Decide(problem, twoFives, threeThrees))
+	assert.Equal(t, Criterion(""), rules.Decide(problem, twoFives, twoFives))
+}
@@ -0,0 +1,289 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/cache"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+	"sync"
+)
+
+const (
+	// maxMemoizedTable is the largest table, in entries, kept between calls;
+	// larger ones are rebuilt for every order.
+	maxMemoizedTable = 1 << 20
+	// maxMemoizedEntries bounds the entries of all tables kept between calls.
+	maxMemoizedEntries = 1 << 23
+)
+
+// memoizedTables keeps the tables of recent catalogs, keyed by their sizes, so
+// they are shared by every order and catalog version with the same sizes.
+var memoizedTables = cache.NewWeightedLRU[string, memoizedTable](maxMemoizedEntries, memoizedTable.entries)
+
+// tableBuilds holds the memoized tables being built, so concurrent orders of a
+// catalog wait for one build instead of each building the table.
+var tableBuilds = struct {
+	lock    sync.Mutex
+	pending map[string]*tableBuild
+}{pending: make(map[string]*tableBuild)}
+
+// tableBudget bounds the entries of the tables built for single orders at
+// once, so concurrent orders wait for memory instead of exhausting it.
+var tableBudget = newEntryBudget(maxExactTotal)
+
+type memoizedTable interface {
+	entries() int
+}
+
+type tableBuild struct {
+	done  chan struct{}
+	table memoizedTable
+	err   error
+}
+
+// memoize returns the table memoized under key, building it once when it is
+// missing. A build stopped by its order's context is retried by the orders
+// waiting for it.
+func memoize[T memoizedTable](ctx context.Context, key string, build func(ctx context.Context) (T, error)) (T, error) {
+	for {
+		if table, found := memoizedTables.Get(key); found {
+			return table.(T), nil
+		}
+		tableBuilds.lock.Lock()
+		pending, building := tableBuilds.pending[key]
+		if !building {
+			pending = &tableBuild{done: make(chan struct{})}
+			tableBuilds.pending[key] = pending
+			tableBuilds.lock.Unlock()
+
+			table, err := build(ctx)
+			if err == nil {
+				memoizedTables.Add(key, table)
+				pending.table = table
+			}
+			pending.err = err
+			tableBuilds.lock.Lock()
+			delete(tableBuilds.pending, key)
+			tableBuilds.lock.Unlock()
+			close(pending.done)
+			return table, err
+		}
+		tableBuilds.lock.Unlock()
+
+		select {
+		case <-pending.done:
+			if pending.err == nil {
+				return pending.table.(T), nil
+			}
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// entryBudget hands out table entries up to a total, waiting for entries
+// given back when they run out.
+type entryBudget struct {
+	lock     sync.Mutex
+	free     int
+	released chan struct{}
+}
+
+func newEntryBudget(entries int) *entryBudget {
+	return &entryBudget{free: entries, released: make(chan struct{})}
+}
+
+// acquire takes entries from the budget, which must not exceed its total.
+func (b *entryBudget) acquire(ctx context.Context, entries int) error {
+	for {
+		b.lock.Lock()
+		if entries <= b.free {
+			b.free -= entries
+			b.lock.Unlock()
+			return nil
+		}
+		released := b.released
+		b.lock.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *entryBudget) release(entries int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.free += entries
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// rulesPlans answers orders under the default objective.
+type rulesPlans interface {
+	solve(problem Problem) ([]domain.CandidatePackages, error)
+}
+
+// planTable holds, for every total in gcd units up to its size, the fewest
+// packs summing to it under the default objective.
+//
+// Past the structural bound (L-1)*s, with L the largest size and s the second
+// largest in gcd units, optimal plans are periodic: L packs smaller than the
+// largest always contain a subset summing to a multiple of L, which fewer
+// largest packs replace, so a plan with the fewest packs holds at most L-1
+// smaller ones. Every total beyond the bound therefore contains a largest
+// pack, and its plan is the plan of the total one largest pack below plus
+// that pack. A table reaching bound+2L answers any amount by shifting the
+// order into it and adding the shifted largest packs back.
+type planTable struct {
+	sizes  []int
+	unit   int
+	period int
+	// bound is the structural bound, or math.MaxInt for tables only built
+	// for one order.
+	bound int
+	// packs[t] is the fewest packs summing to t units (-1 if unreachable) and
+	// last[t] the index of the size added to reach it.
+	packs []int32
+	last  []int32
+}
+
+func newPlanTable(ctx context.Context, sizes []int, unit, size, bound int) (*planTable, error) {
+	table := &planTable{
+		sizes:  sizes,
+		unit:   unit,
+		period: sizes[0] / unit,
+		bound:  bound,
+		packs:  make([]int32, size),
+		last:   make([]int32, size),
+	}
+	for total := 1; total < size; total++ {
+		if total%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		table.packs[total] = -1
+		for i, size := range sizes {
+			previous := total - size/unit
+			if previous < 0 || table.packs[previous] < 0 {
+				continue
+			}
+			if table.packs[total] < 0 || table.packs[previous]+1 < table.packs[total] {
+				table.packs[total] = table.packs[previous] + 1
+				table.last[total] = int32(i)
+			}
+		}
+	}
+	return table, nil
+}
+
+// buildPlanTable builds a table that is not memoized within the tableBudget.
+func buildPlanTable(ctx context.Context, sizes []int, unit, size, bound int) (*planTable, error) {
+	if err := tableBudget.acquire(ctx, size); err != nil {
+		return nil, err
+	}
+	defer tableBudget.release(size)
+	return newPlanTable(ctx, sizes, unit, size, bound)
+}
+
+func (t *planTable) entries() int {
+	return len(t.packs)
+}
+
+// rulesTable returns plans answering the problem's amount: the catalog's
+// periodic table when it is small enough to memoize, otherwise its residue
+// table when that covers the order, otherwise a table covering just this
+// order. Multiples of the smallest size are always reachable, so the least
+// overshooting total lies below the target plus the smallest size.
+func rulesTable(ctx context.Context, sizes []int, unit, target int) (rulesPlans, error) {
+	period := sizes[0] / unit
+	if periodicBound(sizes, unit) <= maxMemoizedTable-2*period {
+		table, err := periodicTable(ctx, sizes, unit)
+		if err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+	if period <= maxMemoizedTable {
+		residues, err := memoize(ctx, fmt.Sprint("residues", sizes), func(ctx context.Context) (*residueTable, error) {
+			return newResidueTable(ctx, sizes, unit)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if residues.covers(target) {
+			return residues, nil
+		}
+	}
+	span := sizes[len(sizes)-1] / unit
+	if span > maxExactTotal || target > maxExactTotal-span {
+		return nil, domain.ErrAmountTooLarge
+	}
+	table, err := buildPlanTable(ctx, sizes, unit, target+span, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// periodicTable returns the catalog's periodic table, memoized when it is
+// small enough, or nil when its bound exceeds maxExactTotal.
+func periodicTable(ctx context.Context, sizes []int, unit int) (*planTable, error) {
+	period := sizes[0] / unit
+	bound := periodicBound(sizes, unit)
+	if bound > maxExactTotal-2*period {
+		return nil, nil
+	}
+	size := bound + 2*period
+	if size > maxMemoizedTable {
+		return buildPlanTable(ctx, sizes, unit, size, bound)
+	}
+	return memoize(ctx, fmt.Sprint(sizes), func(ctx context.Context) (*planTable, error) {
+		return newPlanTable(ctx, sizes, unit, size, bound)
+	})
+}
+
+// periodicBound returns the structural bound of the sizes in gcd units, or
+// math.MaxInt when it exceeds maxExactTotal by far. Every total past it is
+// reachable, as it is past the Frobenius number of the sizes.
+func periodicBound(sizes []int, unit int) int {
+	if len(sizes) == 1 {
+		return 0
+	}
+	period := sizes[0] / unit
+	if second := sizes[1] / unit; second <= maxExactTotal/period {
+		return (period - 1) * second
+	}
+	return math.MaxInt
+}
+
+// solve returns the least overshooting plan for the problem's amount, with
+// the fewest packs among those.
+func (t *planTable) solve(problem Problem) ([]domain.CandidatePackages, error) {
+	target := (problem.Amount + t.unit - 1) / t.unit
+	shift := 0
+	if target > t.bound {
+		shift = (target - t.bound - 1) / t.period
+		target -= shift * t.period
+	}
+
+	for total := target; total < target+t.period; total++ {
+		if t.packs[total] < 0 {
+			continue
+		}
+		if (total+shift*t.period)*t.unit-problem.Amount > problem.wasteLimit() {
+			return nil, domain.ErrAmountUnreachable
+		}
+		best := newCandidate()
+		for ; total > 0; total -= t.sizes[t.last[total]] / t.unit {
+			best.CurrentCombination[t.sizes[t.last[total]]]++
+		}
+		if shift > 0 {
+			best.CurrentCombination[t.sizes[0]] += shift
+		}
+		return []domain.CandidatePackages{best}, nil
+	}
+	return nil, domain.ErrAmountUnreachable
+}
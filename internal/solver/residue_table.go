@@ -0,0 +1,115 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+)
+
+// residueTable answers orders far past the largest size in memory linear in
+// the largest size, for catalogs whose periodic table is too large.
+//
+// With L the largest size in gcd units, an exact plan for t units holds
+// smaller packs summing to some s congruent to t modulo L, and (t-s)/L
+// largest packs: t/L packs plus the weight of its smaller packs, each
+// weighing (L-size)/L. For every residue the table keeps the lightest smaller
+// packs reaching it, the smallest sum among those. When their sum does not
+// exceed t, topping them up with largest packs is the exact plan with the
+// fewest packs, which is the best plan as exact plans overshoot least.
+type residueTable struct {
+	sizes  []int
+	unit   int
+	period int
+	// sums[r] is the total, in units, of the lightest smaller packs reaching
+	// residue r (-1 if unreachable) and last[r] the index of the size added
+	// last to reach it.
+	sums []int
+	last []int32
+}
+
+// newResidueTable finds the lightest packs of every residue one size at a
+// time: adding a size steps through cycles of residues, and going once
+// around each cycle from its lightest residue settles it for that size.
+func newResidueTable(ctx context.Context, sizes []int, unit int) (*residueTable, error) {
+	period := sizes[0] / unit
+	table := &residueTable{
+		sizes:  sizes,
+		unit:   unit,
+		period: period,
+		sums:   make([]int, period),
+		last:   make([]int32, period),
+	}
+	// weights[r] is the weight of the packs reaching r, in 1/L
+	weights := make([]int, period)
+	for residue := 1; residue < period; residue++ {
+		table.sums[residue], weights[residue] = -1, -1
+	}
+	lighter := func(weight, sum, residue int) bool {
+		return weights[residue] < 0 || weight < weights[residue] || weight == weights[residue] && sum < table.sums[residue]
+	}
+
+	steps := 0
+	for i := 1; i < len(sizes); i++ {
+		step := sizes[i] / unit
+		cycles := gcd(step, period)
+		for start := 0; start < cycles; start++ {
+			lightest := -1
+			for residue := start; ; {
+				if weights[residue] >= 0 && (lightest < 0 || lighter(weights[residue], table.sums[residue], lightest)) {
+					lightest = residue
+				}
+				if residue = (residue + step) % period; residue == start {
+					break
+				}
+			}
+			if lightest < 0 {
+				continue
+			}
+			for residue := lightest; ; {
+				if steps++; steps%checkInterval == 0 && ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				next := (residue + step) % period
+				if next == lightest {
+					break
+				}
+				weight, sum := weights[residue]+period-step, table.sums[residue]+step
+				if lighter(weight, sum, next) {
+					weights[next], table.sums[next], table.last[next] = weight, sum, int32(i)
+				}
+				residue = next
+			}
+		}
+	}
+	return table, nil
+}
+
+func (t *residueTable) entries() int {
+	return len(t.sums)
+}
+
+// covers reports whether the table answers orders of target units.
+func (t *residueTable) covers(target int) bool {
+	sum := t.sums[target%t.period]
+	return sum >= 0 && sum <= target
+}
+
+// solve returns the least overshooting plan for the problem's amount, with
+// the fewest packs among those. The table must cover the amount.
+func (t *residueTable) solve(problem Problem) ([]domain.CandidatePackages, error) {
+	target := (problem.Amount + t.unit - 1) / t.unit
+	if target*t.unit-problem.Amount > problem.wasteLimit() {
+		return nil, domain.ErrAmountUnreachable
+	}
+	best := newCandidate()
+	residue := target % t.period
+	best.CurrentCombination[t.sizes[0]] = (target - t.sums[residue]) / t.period
+	for residue != 0 {
+		size := t.sizes[t.last[residue]]
+		best.CurrentCombination[size]++
+		residue = (residue - size/t.unit%t.period + t.period) % t.period
+	}
+	if best.CurrentCombination[t.sizes[0]] == 0 {
+		delete(best.CurrentCombination, t.sizes[0])
+	}
+	return []domain.CandidatePackages{best}, nil
+}
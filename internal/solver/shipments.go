@@ -0,0 +1,239 @@
+package solver
+
+import (
+	"cmp"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+	"slices"
+)
+
+// weightTolerance absorbs the rounding of summed pack weights, so packs
+// filling a shipment exactly to its weight limit still fit.
+const weightTolerance = 1e-9
+
+const (
+	// maxExactShipmentPacks is the largest plan, in packs, searched for the
+	// fewest shipments when filling them one at a time needs more than the
+	// limits require.
+	maxExactShipmentPacks = 40
+	// shipmentSearchBudget caps the placements tried by that search.
+	shipmentSearchBudget = 100_000
+)
+
+// Ship splits packs into shipments within capacity, listing identical
+// shipments once with their count. Each shipment is filled as far as the
+// limits allow, largest packs first, and repeated while enough packs remain;
+// this needs the fewest shipments when the pack sizes divide one another, as
+// they usually do. When it needs more than the limits require, plans of up
+// to maxExactShipmentPacks packs are searched for fewer shipments, see
+// fewestShipments. Weights holds the weight of one pack of each size. A pack
+// that does not fit in an empty shipment fails with
+// domain.ErrPackExceedsCapacity.
+func Ship(packages []*domain.SizedPackage, weights map[int]float64, capacity domain.Capacity) ([]domain.Shipment, error) {
+	remaining := make([]*domain.SizedPackage, 0, len(packages))
+	for _, pkg := range packages {
+		if pkg.Quantity <= 0 {
+			continue
+		}
+		if fits(capacity, weights, pkg.Size, 0, 0, 0) == 0 {
+			return nil, fmt.Errorf("%w: a pack of %d items", domain.ErrPackExceedsCapacity, pkg.Size)
+		}
+		remaining = append(remaining, &domain.SizedPackage{Size: pkg.Size, Quantity: pkg.Quantity})
+	}
+	slices.SortFunc(remaining, func(a, b *domain.SizedPackage) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+
+	shipments := make([]domain.Shipment, 0)
+	for slices.ContainsFunc(remaining, func(pkg *domain.SizedPackage) bool { return pkg.Quantity > 0 }) {
+		shipment := fill(remaining, weights, capacity)
+		for _, pkg := range shipment.Packages {
+			shipment.Count = min(shipment.Count, quantity(remaining, pkg.Size)/pkg.Quantity)
+		}
+		for _, pkg := range remaining {
+			pkg.Quantity -= shipment.Count * quantity(shipment.Packages, pkg.Size)
+		}
+		shipments = append(shipments, shipment)
+	}
+
+	count, packs := 0, 0
+	for _, shipment := range shipments {
+		count += shipment.Count
+		packs += shipment.Count * shipment.NumberOfPackages
+	}
+	if packs <= maxExactShipmentPacks && count > minShipments(packages, weights, capacity) {
+		if fewer := fewestShipments(packages, weights, capacity, count-1); fewer != nil {
+			return fewer, nil
+		}
+	}
+	return shipments, nil
+}
+
+// minShipments bounds the shipments needed from below by the items, packs
+// and weight of the packs against each limit alone.
+func minShipments(packages []*domain.SizedPackage, weights map[int]float64, capacity domain.Capacity) int {
+	items, packs, weight := 0, 0, 0.0
+	for _, pkg := range packages {
+		if pkg.Quantity <= 0 {
+			continue
+		}
+		items += pkg.Quantity * pkg.Size
+		packs += pkg.Quantity
+		weight += float64(pkg.Quantity) * weights[pkg.Size]
+	}
+	bound := 1
+	if capacity.MaxItems > 0 {
+		bound = max(bound, (items+capacity.MaxItems-1)/capacity.MaxItems)
+	}
+	if capacity.MaxPacks > 0 {
+		bound = max(bound, (packs+capacity.MaxPacks-1)/capacity.MaxPacks)
+	}
+	if capacity.MaxWeight > 0 {
+		bound = max(bound, int(math.Ceil(weight/capacity.MaxWeight-weightTolerance)))
+	}
+	return bound
+}
+
+// fewestShipments searches the fewest shipments, at most limit, holding the
+// packs, trying every count from the lower bound up. Packs are placed largest
+// first, identical packs in non-decreasing shipments and never in a shipment
+// loaded like an earlier one, as those placements repeat ones already tried.
+// It returns nil when limit shipments do not suffice or the search exceeds
+// shipmentSearchBudget.
+func fewestShipments(packages []*domain.SizedPackage, weights map[int]float64, capacity domain.Capacity, limit int) []domain.Shipment {
+	sizes := make([]int, 0, maxExactShipmentPacks)
+	for _, pkg := range packages {
+		for i := 0; i < pkg.Quantity; i++ {
+			sizes = append(sizes, pkg.Size)
+		}
+	}
+	slices.SortFunc(sizes, func(a, b int) int {
+		return cmp.Compare(b, a)
+	})
+
+	budget := shipmentSearchBudget
+	placed := make([]int, len(sizes))
+	for count := minShipments(packages, weights, capacity); count <= limit; count++ {
+		loads := make([]domain.Shipment, count)
+		var place func(i int) bool
+		place = func(i int) bool {
+			if i == len(sizes) {
+				return true
+			}
+			if budget--; budget < 0 {
+				return false
+			}
+			first := 0
+			if i > 0 && sizes[i] == sizes[i-1] {
+				first = placed[i-1]
+			}
+			for b := first; b < count; b++ {
+				load := &loads[b]
+				if fits(capacity, weights, sizes[i], load.Items, load.NumberOfPackages, load.Weight) == 0 {
+					continue
+				}
+				if slices.ContainsFunc(loads[first:b], func(other domain.Shipment) bool {
+					return other.Items == load.Items && other.NumberOfPackages == load.NumberOfPackages && other.Weight == load.Weight
+				}) {
+					continue
+				}
+				placed[i] = b
+				load.Items += sizes[i]
+				load.NumberOfPackages++
+				load.Weight += weights[sizes[i]]
+				if place(i + 1) {
+					return true
+				}
+				load.Items -= sizes[i]
+				load.NumberOfPackages--
+				load.Weight -= weights[sizes[i]]
+			}
+			return false
+		}
+		if place(0) {
+			return shipmentsOf(sizes, placed, weights, count)
+		}
+		if budget < 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// shipmentsOf lists the shipments the packs of sizes are placed in, identical
+// ones once with their count.
+func shipmentsOf(sizes, placed []int, weights map[int]float64, count int) []domain.Shipment {
+	loads := make([]domain.Shipment, count)
+	for i, size := range sizes {
+		load := &loads[placed[i]]
+		// sizes are sorted, so the packs of a size are listed together
+		if n := len(load.Packages); n > 0 && load.Packages[n-1].Size == size {
+			load.Packages[n-1].Quantity++
+		} else {
+			load.Packages = append(load.Packages, &domain.SizedPackage{Size: size, Quantity: 1})
+		}
+		load.Items += size
+		load.NumberOfPackages++
+		load.Weight += weights[size]
+	}
+
+	shipments := make([]domain.Shipment, 0, count)
+	for _, load := range loads {
+		i := slices.IndexFunc(shipments, func(shipment domain.Shipment) bool {
+			return slices.EqualFunc(shipment.Packages, load.Packages, func(a, b *domain.SizedPackage) bool {
+				return *a == *b
+			})
+		})
+		if i >= 0 {
+			shipments[i].Count++
+			continue
+		}
+		load.Count = 1
+		shipments = append(shipments, load)
+	}
+	return shipments
+}
+
+// fill packs one shipment from the remaining packs, largest first. Its Count
+// is left at math.MaxInt for the caller to bound.
+func fill(remaining []*domain.SizedPackage, weights map[int]float64, capacity domain.Capacity) domain.Shipment {
+	shipment := domain.Shipment{Count: math.MaxInt}
+	for _, pkg := range remaining {
+		packs := min(pkg.Quantity, fits(capacity, weights, pkg.Size, shipment.Items, shipment.NumberOfPackages, shipment.Weight))
+		if packs == 0 {
+			continue
+		}
+		shipment.Packages = append(shipment.Packages, &domain.SizedPackage{Size: pkg.Size, Quantity: packs})
+		shipment.Items += packs * pkg.Size
+		shipment.NumberOfPackages += packs
+		shipment.Weight += float64(packs) * weights[pkg.Size]
+	}
+	return shipment
+}
+
+// fits returns how many more packs of size a shipment already holding items,
+// packs and weight can take, math.MaxInt when no limit applies.
+func fits(capacity domain.Capacity, weights map[int]float64, size, items, packs int, weight float64) int {
+	more := math.MaxInt
+	if capacity.MaxItems > 0 {
+		more = min(more, (capacity.MaxItems-items)/size)
+	}
+	if capacity.MaxPacks > 0 {
+		more = min(more, capacity.MaxPacks-packs)
+	}
+	if packWeight := weights[size]; capacity.MaxWeight > 0 && packWeight > 0 {
+		more = min(more, int(min(math.Floor((capacity.MaxWeight-weight)/packWeight+weightTolerance), math.MaxInt32)))
+	}
+	return max(more, 0)
+}
+
+// quantity returns how many packs of size the packages hold.
+func quantity(packages []*domain.SizedPackage, size int) int {
+	for _, pkg := range packages {
+		if pkg.Size == size {
+			return pkg.Quantity
+		}
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package solver
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"testing"
+)
+
+func TestShip(t *testing.T) {
+	packages := []*domain.SizedPackage{{Size: 500, Quantity: 3}, {Size: 1000, Quantity: 5}, {Size: 250, Quantity: 1}}
+	weights := map[int]float64{1000: 10, 500: 5.5, 250: 3}
+
+	testCases := []struct {
+		name          string
+		capacity      domain.Capacity
+		expected      []domain.Shipment
+		expectedError error
+	}{
+		{
+			name:     "Unlimited",
+			capacity: domain.Capacity{},
+			expected: []domain.Shipment{{
+				Packages:         []*domain.SizedPackage{{Size: 1000, Quantity: 5}, {Size: 500, Quantity: 3}, {Size: 250, Quantity: 1}},
+				Count:            1,
+				Items:            6750,
+				NumberOfPackages: 9,
+				Weight:           69.5,
+			}},
+		},
+		{
+			name:     "Max packs",
+			capacity: domain.Capacity{MaxPacks: 4},
+			expected: []domain.Shipment{
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 4}}, Count: 1, Items: 4000, NumberOfPackages: 4, Weight: 40},
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 1}, {Size: 500, Quantity: 3}}, Count: 1, Items: 2500, NumberOfPackages: 4, Weight: 26.5},
+				{Packages: []*domain.SizedPackage{{Size: 250, Quantity: 1}}, Count: 1, Items: 250, NumberOfPackages: 1, Weight: 3},
+			},
+		},
+		{
+			name:     "Max items",
+			capacity: domain.Capacity{MaxItems: 2000},
+			expected: []domain.Shipment{
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 2}}, Count: 2, Items: 2000, NumberOfPackages: 2, Weight: 20},
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 1}, {Size: 500, Quantity: 2}}, Count: 1, Items: 2000, NumberOfPackages: 3, Weight: 21},
+				{Packages: []*domain.SizedPackage{{Size: 500, Quantity: 1}, {Size: 250, Quantity: 1}}, Count: 1, Items: 750, NumberOfPackages: 2, Weight: 8.5},
+			},
+		},
+		{
+			name:     "Max weight",
+			capacity: domain.Capacity{MaxWeight: 21},
+			// the second shipment reaches the limit exactly
+			expected: []domain.Shipment{
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 2}}, Count: 2, Items: 2000, NumberOfPackages: 2, Weight: 20},
+				{Packages: []*domain.SizedPackage{{Size: 1000, Quantity: 1}, {Size: 500, Quantity: 2}}, Count: 1, Items: 2000, NumberOfPackages: 3, Weight: 21},
+				{Packages: []*domain.SizedPackage{{Size: 500, Quantity: 1}, {Size: 250, Quantity: 1}}, Count: 1, Items: 750, NumberOfPackages: 2, Weight: 8.5},
+			},
+		},
+		{
+			name:          "Pack exceeds capacity",
+			capacity:      domain.Capacity{MaxItems: 750},
+			expectedError: domain.ErrPackExceedsCapacity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shipments, err := Ship(packages, weights, tc.capacity)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, shipments)
+		})
+	}
+
+	t.Run("Fewest shipments", func(t *testing.T) {
+		// filling the first shipment with 5 and 4 leaves 3, 3, 3 and 2 for
+		// two more
+		packages := []*domain.SizedPackage{{Size: 5, Quantity: 1}, {Size: 4, Quantity: 1}, {Size: 3, Quantity: 3}, {Size: 2, Quantity: 1}}
+
+		shipments, err := Ship(packages, nil, domain.Capacity{MaxItems: 10})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Shipment{
+			{Packages: []*domain.SizedPackage{{Size: 5, Quantity: 1}, {Size: 3, Quantity: 1}, {Size: 2, Quantity: 1}}, Count: 1, Items: 10, NumberOfPackages: 3},
+			{Packages: []*domain.SizedPackage{{Size: 4, Quantity: 1}, {Size: 3, Quantity: 2}}, Count: 1, Items: 10, NumberOfPackages: 3},
+		}, shipments)
+
+		shipments, err = Ship([]*domain.SizedPackage{{Size: 6, Quantity: 2}, {Size: 4, Quantity: 2}}, nil, domain.Capacity{MaxItems: 10})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Shipment{
+			{Packages: []*domain.SizedPackage{{Size: 6, Quantity: 1}, {Size: 4, Quantity: 1}}, Count: 2, Items: 10, NumberOfPackages: 2},
+		}, shipments, "identical shipments are listed once")
+	})
+
+	t.Run("Huge plans", func(t *testing.T) {
+		shipments, err := Ship([]*domain.SizedPackage{{Size: 5000, Quantity: 1 << 40}}, nil, domain.Capacity{MaxPacks: 3})
+
+		assert.NoError(t, err)
+		assert.Len(t, shipments, 2)
+		assert.Equal(t, (1<<40)/3, shipments[0].Count)
+		assert.Equal(t, 1, shipments[1].NumberOfPackages)
+	})
+}
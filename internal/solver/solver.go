@@ -0,0 +1,193 @@
+package solver
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+	"slices"
+	"sync"
+)
+
+const (
+	Exact          = "exact"
+	Greedy         = "greedy"
+	BranchAndBound = "branch-and-bound"
+	Heuristic      = "heuristic"
+
+	// DefaultStrategy is used when neither the configuration nor the request
+	// names a strategy.
+	DefaultStrategy = Exact
+
+	// checkInterval is how many steps a search takes between checks of its
+	// context.
+	checkInterval = 1 << 16
+)
+
+// Problem is a single packing request: the available pack sizes, distinct and
+// sorted descending, the number of items to ship and how many runner-up plans
+// to return besides the best one. A nil Stock means every size is available
+// in unlimited quantity; otherwise it maps each size to the packs on hand.
+// Costs holds the unit cost of each size for the Cost criterion and Weights
+// the weight of one pack of each size for splitting plans into shipments. A
+// positive MaxWaste rejects plans shipping more surplus items than that.
+// ExactOnly rejects every plan shipping surplus items.
+type Problem struct {
+	Sizes        []int
+	Amount       int
+	Alternatives int
+	Stock        map[int]int
+	Costs        map[int]float64
+	Weights      map[int]float64
+	Objective    Objective
+	MaxWaste     int
+	ExactOnly    bool
+}
+
+// NewProblem builds a Problem from the catalog, dropping duplicate and
+// non-positive sizes.
+func NewProblem(packages []*domain.Package, amount int) Problem {
+	sizes := make([]int, 0, len(packages))
+	costs := make(map[int]float64, len(packages))
+	weights := make(map[int]float64, len(packages))
+	for _, pkg := range packages {
+		if pkg.Size > 0 {
+			sizes = append(sizes, pkg.Size)
+			costs[pkg.Size] = pkg.Cost
+			weights[pkg.Size] = pkg.Weight
+		}
+	}
+	slices.SortFunc(sizes, func(a, b int) int {
+		return cmp.Compare(b, a)
+	})
+	return Problem{
+		Sizes:   slices.Compact(sizes),
+		Amount:  amount,
+		Costs:   costs,
+		Weights: weights,
+	}
+}
+
+// WithStock limits the problem to the packs on hand in the catalog.
+func (p Problem) WithStock(packages []*domain.Package) Problem {
+	p.Stock = make(map[int]int, len(packages))
+	for _, pkg := range packages {
+		if pkg.Size > 0 {
+			p.Stock[pkg.Size] += max(pkg.Stock, 0)
+		}
+	}
+	return p
+}
+
+// available returns how many packs of size may be used.
+func (p Problem) available(size int) int {
+	if p.Stock == nil {
+		return math.MaxInt
+	}
+	return p.Stock[size]
+}
+
+// wasteLimit returns the most surplus items a plan may ship.
+func (p Problem) wasteLimit() int {
+	switch {
+	case p.ExactOnly:
+		return 0
+	case p.MaxWaste > 0:
+		return p.MaxWaste
+	default:
+		return math.MaxInt
+	}
+}
+
+// check reports problems no strategy can solve: an empty catalog, or less
+// stock than the order needs.
+func (p Problem) check() error {
+	if len(p.Sizes) == 0 {
+		return domain.ErrNoPackageSizes
+	}
+	if p.Stock == nil {
+		return nil
+	}
+	// the stock is compared before it is added up, so large stocks of large
+	// sizes cannot overflow the sum
+	inStock := 0
+	for _, size := range p.Sizes {
+		remaining := p.Amount - inStock
+		needed := remaining / size
+		if remaining%size > 0 {
+			needed++
+		}
+		available := p.available(size)
+		if available >= needed {
+			return nil
+		}
+		inStock += size * available
+	}
+	return fmt.Errorf("%w: %d items in stock for an order of %d", domain.ErrAmountUnreachable, inStock, p.Amount)
+}
+
+// Solver picks combinations of packs covering Problem.Amount, best first. It
+// returns at most 1+Problem.Alternatives plans; strategies that only produce
+// one plan ignore Alternatives. Failures are reported with the domain errors,
+// e.g. domain.ErrNoPackageSizes, or with the context's error when it is done
+// before the search completes.
+type Solver interface {
+	Solve(ctx context.Context, problem Problem) ([]domain.CandidatePackages, error)
+}
+
+var (
+	registry = map[string]Solver{
+		Exact:          ExactSolver{},
+		Greedy:         GreedySolver{},
+		BranchAndBound: BranchAndBoundSolver{},
+		Heuristic:      HeuristicSolver{},
+	}
+	registryLock sync.RWMutex
+)
+
+// Register makes a solver available under name, replacing any solver
+// previously registered with that name.
+func Register(name string, solver Solver) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[name] = solver
+}
+
+// Unregister removes the solver registered under name, if any.
+func Unregister(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, name)
+}
+
+// Get returns the solver registered under name.
+func Get(name string) (Solver, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	solver, ok := registry[name]
+	return solver, ok
+}
+
+// Names lists the registered strategies in alphabetical order.
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func newCandidate() domain.CandidatePackages {
+	return domain.CandidatePackages{CurrentCombination: make(map[int]int)}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,341 @@
+package solver
+
+import (
+	"cmp"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNewProblem(t *testing.T) {
+	packages := []*domain.Package{
+		{Size: 250, Stock: 3, Cost: 1.5}, {Size: 1000, Stock: 1, Weight: 8}, {Size: 0, Stock: 9}, {Size: 500, Cost: 2}, {Size: 1000, Stock: 2, Weight: 8},
+	}
+
+	problem := NewProblem(packages, 42)
+
+	assert.Equal(t, Problem{
+		Sizes:   []int{1000, 500, 250},
+		Amount:  42,
+		Costs:   map[int]float64{1000: 0, 500: 2, 250: 1.5},
+		Weights: map[int]float64{1000: 8, 500: 0, 250: 0},
+	}, problem)
+	assert.Equal(t, map[int]int{1000: 3, 500: 0, 250: 3}, problem.WithStock(packages).Stock)
+}
+
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, []string{BranchAndBound, Exact, Greedy, Heuristic}, Names())
+
+	_, ok := Get("unknown")
+	assert.False(t, ok)
+
+	Register("custom", GreedySolver{})
+	custom, ok := Get("custom")
+	assert.True(t, ok)
+	assert.Equal(t, GreedySolver{}, custom)
+
+	Unregister("custom")
+	_, ok = Get("custom")
+	assert.False(t, ok)
+	assert.Equal(t, []string{BranchAndBound, Exact, Greedy, Heuristic}, Names())
+}
+
+func TestSolvers_AgreeWithExact(t *testing.T) {
+	testCases := []struct {
+		name  string
+		sizes []int
+	}{
+		{name: "Standard catalog", sizes: []int{5000, 2000, 1000, 500, 250}},
+		{name: "Awkward sizes", sizes: []int{53, 31, 23}},
+		{name: "Sizes sharing a divisor", sizes: []int{15, 9, 6}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for amount := 1; amount <= 3000; amount += 7 {
+				problem := Problem{Sizes: tc.sizes, Amount: amount}
+				expected, _ := ExactSolver{}.Solve(context.Background(), problem)
+
+				for _, solver := range []Solver{BranchAndBoundSolver{}, HeuristicSolver{}} {
+					candidates, err := solver.Solve(context.Background(), problem)
+					assert.NoError(t, err)
+					assert.Equal(t, 0, candidates[0].Compare(expected[0], amount), "%T amount %d", solver, amount)
+				}
+
+				greedy, err := GreedySolver{}.Solve(context.Background(), problem)
+				assert.NoError(t, err)
+				assert.GreaterOrEqual(t, greedy[0].Waste(amount), 0, "greedy amount %d", amount)
+			}
+		})
+	}
+
+	t.Run("Heuristic on a huge amount", func(t *testing.T) {
+		problem := Problem{Sizes: []int{5000, 2000, 1000, 500, 250}, Amount: 50_000_001}
+
+		candidates, err := HeuristicSolver{}.Solve(context.Background(), problem)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 249, candidates[0].Waste(problem.Amount))
+		assert.Equal(t, 10001, candidates[0].NumberOfPackages())
+	})
+
+	t.Run("Empty catalog", func(t *testing.T) {
+		for _, name := range Names() {
+			solver, _ := Get(name)
+			_, err := solver.Solve(context.Background(), Problem{Amount: 10})
+			assert.ErrorIs(t, err, domain.ErrNoPackageSizes, name)
+		}
+	})
+}
+
+func TestSolvers_Alternatives(t *testing.T) {
+	type key struct{ waste, packs int }
+	sizes := []int{13, 11, 7}
+
+	for amount := 1; amount <= 120; amount++ {
+		// exhaustive ranking of the minimal plans, i.e. plans that fall short
+		// of the amount once any single pack is removed
+		var expected []key
+		for a := 0; a*sizes[0] < amount+sizes[0]; a++ {
+			for b := 0; b*sizes[1] < amount+sizes[1]; b++ {
+				for c := 0; c*sizes[2] < amount+sizes[2]; c++ {
+					total := a*sizes[0] + b*sizes[1] + c*sizes[2]
+					smallest := sizes[0]
+					for i, count := range []int{a, b, c} {
+						if count > 0 {
+							smallest = sizes[i]
+						}
+					}
+					if total < amount || total-smallest >= amount {
+						continue
+					}
+					expected = append(expected, key{total - amount, a + b + c})
+				}
+			}
+		}
+		slices.SortFunc(expected, func(x, y key) int {
+			if x.waste != y.waste {
+				return cmp.Compare(x.waste, y.waste)
+			}
+			return cmp.Compare(x.packs, y.packs)
+		})
+		expected = expected[:min(len(expected), 4)]
+
+		for _, solver := range []Solver{ExactSolver{}, BranchAndBoundSolver{}} {
+			candidates, err := solver.Solve(context.Background(), Problem{Sizes: sizes, Amount: amount, Alternatives: 3})
+			assert.NoError(t, err)
+
+			actual := make([]key, 0, len(candidates))
+			for _, candidate := range candidates {
+				actual = append(actual, key{candidate.Waste(amount), candidate.NumberOfPackages()})
+			}
+			assert.Equal(t, expected, actual, "%T amount %d", solver, amount)
+		}
+	}
+
+	t.Run("Large amount", func(t *testing.T) {
+		candidates, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: []int{53, 31, 23}, Amount: 500000, Alternatives: 4})
+
+		assert.NoError(t, err)
+		assert.Len(t, candidates, 5)
+		assert.Equal(t, 9438, candidates[0].NumberOfPackages())
+		for i := 1; i < len(candidates); i++ {
+			assert.LessOrEqual(t, candidates[i-1].Compare(candidates[i], 500000), 0)
+		}
+	})
+}
+
+func TestSolvers_Stock(t *testing.T) {
+	sizes := []int{13, 11, 7}
+	stock := map[int]int{13: 2, 11: 1, 7: 5}
+
+	for amount := 1; amount <= 70; amount++ {
+		problem := Problem{Sizes: sizes, Amount: amount, Stock: stock}
+
+		found := false
+		var best struct{ waste, packs int }
+		for a := 0; a <= stock[13]; a++ {
+			for b := 0; b <= stock[11]; b++ {
+				for c := 0; c <= stock[7]; c++ {
+					waste, packs := a*13+b*11+c*7-amount, a+b+c
+					if waste < 0 {
+						continue
+					}
+					if !found || waste < best.waste || (waste == best.waste && packs < best.packs) {
+						best.waste, best.packs, found = waste, packs, true
+					}
+				}
+			}
+		}
+
+		for _, name := range Names() {
+			solver, _ := Get(name)
+			candidates, err := solver.Solve(context.Background(), problem)
+			if !found {
+				assert.ErrorIs(t, err, domain.ErrAmountUnreachable, "%s amount %d", name, amount)
+				continue
+			}
+			assert.NoError(t, err, "%s amount %d", name, amount)
+			for size, count := range candidates[0].CurrentCombination {
+				assert.LessOrEqual(t, count, stock[size], "%s amount %d", name, amount)
+			}
+			assert.GreaterOrEqual(t, candidates[0].Waste(amount), 0, "%s amount %d", name, amount)
+			if name != Greedy {
+				assert.Equal(t, best.waste, candidates[0].Waste(amount), "%s amount %d", name, amount)
+				assert.Equal(t, best.packs, candidates[0].NumberOfPackages(), "%s amount %d", name, amount)
+			}
+		}
+	}
+
+	t.Run("Not enough stock", func(t *testing.T) {
+		_, err := ExactSolver{}.Solve(context.Background(), Problem{Sizes: sizes, Amount: 100, Stock: stock})
+
+		assert.EqualError(t, err, "amount cannot be fulfilled with the available packages: 72 items in stock for an order of 100")
+	})
+
+	t.Run("Stock beyond the integer range", func(t *testing.T) {
+		huge := Problem{Sizes: []int{1 << 40, 1 << 30}, Amount: 1 << 50, Stock: map[int]int{1 << 40: 1 << 30, 1 << 30: 1 << 40}}
+
+		assert.NoError(t, huge.check())
+
+		huge.Amount, huge.Stock = math.MaxInt, map[int]int{1 << 40: 1 << 22, 1 << 30: 1}
+		assert.ErrorIs(t, huge.check(), domain.ErrAmountUnreachable)
+	})
+}
+
+func TestSolvers_Objectives(t *testing.T) {
+	sizes := []int{13, 11, 7}
+	costs := map[int]float64{13: 5, 11: 2, 7: 3}
+	objectives := []string{MinimizeCost, MinimizePacks, "distinct_sizes,packs", "waste:1,packs:5", "cost:2,distinct_sizes:3"}
+
+	for _, definition := range objectives {
+		objective, err := ParseObjective(definition)
+		assert.NoError(t, err)
+
+		for _, maxWaste := range []int{0, 2, 6} {
+			for amount := 1; amount <= 80; amount++ {
+				problem := Problem{Sizes: sizes, Amount: amount, Costs: costs, Objective: objective, MaxWaste: maxWaste}
+
+				found := false
+				var best planKey
+				for a := 0; a*13 < amount+13; a++ {
+					for b := 0; b*11 < amount+11; b++ {
+						for c := 0; c*7 < amount+7; c++ {
+							key := planKey{
+								waste: a*13 + b*11 + c*7 - amount,
+								packs: a + b + c,
+								cost:  float64(a)*costs[13] + float64(b)*costs[11] + float64(c)*costs[7],
+							}
+							for _, count := range []int{a, b, c} {
+								if count > 0 {
+									key.sizes++
+								}
+							}
+							if key.waste < 0 || (maxWaste > 0 && key.waste > maxWaste) {
+								continue
+							}
+							if !found || objective.compare(key, best) < 0 {
+								best, found = key, true
+							}
+						}
+					}
+				}
+
+				for _, solver := range []Solver{ExactSolver{}, BranchAndBoundSolver{}} {
+					candidates, err := solver.Solve(context.Background(), problem)
+					if !found {
+						assert.ErrorIs(t, err, domain.ErrAmountUnreachable, "%T %s amount %d max waste %d", solver, definition, amount, maxWaste)
+						continue
+					}
+					assert.NoError(t, err)
+					actual := planKey{
+						waste: candidates[0].Waste(amount),
+						packs: candidates[0].NumberOfPackages(),
+						cost:  candidates[0].Cost(costs),
+						sizes: len(candidates[0].CurrentCombination),
+					}
+					assert.Equal(t, 0, objective.compare(best, actual), "%T %s amount %d max waste %d", solver, definition, amount, maxWaste)
+				}
+			}
+		}
+	}
+}
+
+func TestSolvers_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	problem := Problem{Sizes: []int{53, 31, 23}, Amount: 500000, Alternatives: 4}
+
+	for _, solver := range []Solver{ExactSolver{}, BranchAndBoundSolver{}} {
+		_, err := solver.Solve(ctx, problem)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestUnderfill(t *testing.T) {
+	testCases := []struct {
+		name          string
+		problem       Problem
+		expected      map[int]int
+		expectedFound bool
+	}{
+		{name: "Exact fit", problem: Problem{Sizes: []int{5, 3}, Amount: 11}, expected: map[int]int{5: 1, 3: 2}, expectedFound: true},
+		{name: "Short of the amount", problem: Problem{Sizes: []int{10, 4}, Amount: 11}, expected: map[int]int{10: 1}, expectedFound: true},
+		{name: "Within stock", problem: Problem{Sizes: []int{5, 3}, Amount: 11, Stock: map[int]int{5: 2, 3: 0}}, expected: map[int]int{5: 2}, expectedFound: true},
+		{name: "Nothing fits", problem: Problem{Sizes: []int{5, 3}, Amount: 2}, expectedFound: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			candidate, found, err := Underfill(context.Background(), tc.problem)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFound, found)
+			if tc.expectedFound {
+				assert.Equal(t, tc.expected, candidate.CurrentCombination)
+			}
+		})
+	}
+
+	t.Run("Amounts that are not positive", func(t *testing.T) {
+		for _, amount := range []int{0, -10} {
+			candidate, found, err := Underfill(context.Background(), Problem{Sizes: []int{3}, Amount: amount})
+
+			assert.NoError(t, err)
+			assert.False(t, found)
+			assert.Zero(t, candidate.NumberOfPackages())
+		}
+	})
+
+	t.Run("Huge amount", func(t *testing.T) {
+		amount := 1<<40 + 1
+		candidate, found, err := Underfill(context.Background(), Problem{Sizes: []int{10, 4}, Amount: amount})
+
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, -1, candidate.Waste(amount))
+	})
+}
+
+func TestSolvers_ExactOnly(t *testing.T) {
+	for name, solver := range map[string]Solver{Exact: ExactSolver{}, Greedy: GreedySolver{}, BranchAndBound: BranchAndBoundSolver{}} {
+		t.Run(name, func(t *testing.T) {
+			_, err := solver.Solve(context.Background(), Problem{Sizes: []int{5, 3}, Amount: 7, ExactOnly: true})
+
+			assert.ErrorIs(t, err, domain.ErrAmountUnreachable)
+		})
+	}
+
+	for name, solver := range map[string]Solver{Exact: ExactSolver{}, BranchAndBound: BranchAndBoundSolver{}} {
+		t.Run(name+" exact fit", func(t *testing.T) {
+			candidates, err := solver.Solve(context.Background(), Problem{Sizes: []int{5, 3}, Amount: 11, ExactOnly: true})
+
+			assert.NoError(t, err)
+			assert.Equal(t, map[int]int{5: 1, 3: 2}, candidates[0].CurrentCombination)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package solver
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+)
+
+// Underfill returns the plan shipping the most items without exceeding the
+// problem's amount, within stock, and false when not even the smallest pack
+// fits. Without stock limits every total past the sizes' periodic bound is
+// reachable, so large amounts only round down to the gcd of the sizes;
+// otherwise every total up to the amount is searched, and amounts beyond
+// maxExactTotal gcd units are reported as domain.ErrAmountTooLarge. Amounts
+// that are not positive fit no pack and get the empty plan.
+func Underfill(ctx context.Context, problem Problem) (domain.CandidatePackages, bool, error) {
+	if len(problem.Sizes) == 0 {
+		return domain.CandidatePackages{}, false, domain.ErrNoPackageSizes
+	}
+	if problem.Amount <= 0 {
+		return newCandidate(), false, nil
+	}
+	unit := problem.Sizes[0]
+	for _, size := range problem.Sizes[1:] {
+		unit = gcd(unit, size)
+	}
+	top := problem.Amount / unit
+	if problem.Stock == nil && top > periodicBound(problem.Sizes, unit) {
+		candidates, err := ExactSolver{}.Solve(ctx, Problem{Sizes: problem.Sizes, Amount: top * unit})
+		if err != nil {
+			return domain.CandidatePackages{}, false, err
+		}
+		return candidates[0], true, nil
+	}
+	if top > maxExactTotal {
+		return domain.CandidatePackages{}, false, domain.ErrAmountTooLarge
+	}
+	if err := tableBudget.acquire(ctx, top+1); err != nil {
+		return domain.CandidatePackages{}, false, err
+	}
+	defer tableBudget.release(top + 1)
+
+	// by[t] is the index of the size that first reached t and used[t] how many
+	// packs of it that took, so no size is used beyond its stock.
+	reachable := make([]bool, top+1)
+	by := make([]int32, top+1)
+	used := make([]int32, top+1)
+	reachable[0] = true
+	for i, size := range problem.Sizes {
+		step := size / unit
+		available := problem.available(size)
+		clear(used)
+		for total := step; total <= top; total++ {
+			if total%checkInterval == 0 && ctx.Err() != nil {
+				return domain.CandidatePackages{}, false, ctx.Err()
+			}
+			if !reachable[total] && reachable[total-step] && int(used[total-step]) < available {
+				reachable[total] = true
+				used[total] = used[total-step] + 1
+				by[total] = int32(i)
+			}
+		}
+	}
+
+	for total := top; total > 0; total-- {
+		if !reachable[total] {
+			continue
+		}
+		best := newCandidate()
+		for ; total > 0; total -= problem.Sizes[by[total]] / unit {
+			best.CurrentCombination[problem.Sizes[by[total]]]++
+		}
+		return best, true, nil
+	}
+	return domain.CandidatePackages{}, false, nil
+}
@@ -0,0 +1,425 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/repository"
+	"github/ahmedghazey/packaging/internal/storage/inmemory"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ repository.PackageRepository = (*Storage)(nil)
+
+const (
+	logName      = "packages.log"
+	snapshotName = "packages.snapshot"
+)
+
+// DefaultSnapshotEvery is the number of logged writes after which the log is
+// compacted into a snapshot when no positive interval is configured.
+const DefaultSnapshotEvery = 1000
+
+type operation string
+
+const (
+	opCreate      operation = "create"
+	opCreateAll   operation = "create_all"
+	opUpdate      operation = "update"
+	opDelete      operation = "delete"
+	opAdjustStock operation = "adjust_stock"
+	opReplaceAll  operation = "replace_all"
+)
+
+// record is one write in the log. Records are numbered so the ones already
+// in the snapshot are skipped when the log could not be emptied after it.
+type record struct {
+	Seq      uint64            `json:"seq"`
+	Op       operation         `json:"op"`
+	Id       string            `json:"id,omitempty"`
+	Package  *domain.Package   `json:"package,omitempty"`
+	Packages []*domain.Package `json:"packages,omitempty"`
+	Deltas   map[string]int    `json:"deltas,omitempty"`
+}
+
+type snapshot struct {
+	Seq      uint64            `json:"seq"`
+	Packages []*domain.Package `json:"packages"`
+}
+
+// Storage keeps the packages in memory and makes every write durable in an
+// append-only log in its directory before acknowledging it. Every
+// snapshotEvery writes the packages are written to a snapshot, which replaces
+// the log. Opening the storage loads the snapshot and replays the log; a
+// record torn by a crash at the end of the log is dropped, as its write was
+// never acknowledged.
+type Storage struct {
+	dir           string
+	snapshotEvery int
+
+	lock    sync.Mutex
+	items   *inmemory.Storage
+	log     *os.File
+	size    int64
+	seq     uint64
+	pending int
+}
+
+// NewStorage opens the storage in dir, creating the directory when missing.
+func NewStorage(dir string, snapshotEvery int) (*Storage, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	s := &Storage{
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+		items:         inmemory.NewStorage(),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the log. Writes fail once the storage is closed.
+func (s *Storage) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.log.Close()
+}
+
+// Create adds a new Package item to the storage.
+func (s *Storage) Create(item *domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.items.Create(item); err != nil {
+		return err
+	}
+	if err := s.append(record{Op: opCreate, Package: item}); err != nil {
+		s.items.Delete(item.Id)
+		return err
+	}
+	return nil
+}
+
+// CreateAll adds the Package items to the storage in one record, all or none.
+func (s *Storage) CreateAll(items []*domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.items.CreateAll(items); err != nil {
+		return err
+	}
+	if err := s.append(record{Op: opCreateAll, Packages: items}); err != nil {
+		for _, item := range items {
+			s.items.Delete(item.Id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get retrieves a Package item from the storage by ID.
+func (s *Storage) Get(id string) (*domain.Package, error) {
+	return s.items.Get(id)
+}
+
+// Update updates a Package item in the storage by ID.
+func (s *Storage) Update(id string, updatedPackage *domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	previous, err := s.items.Get(id)
+	if err != nil {
+		return err
+	}
+	if err = s.items.Update(id, updatedPackage); err != nil {
+		return err
+	}
+	if err = s.append(record{Op: opUpdate, Id: id, Package: updatedPackage}); err != nil {
+		s.items.Update(id, previous)
+		return err
+	}
+	return nil
+}
+
+// Patch changes the patch's fields of a Package item in the storage by ID. The
+// result is logged as an update, so stock adjustments, which take the same
+// lock, are never lost.
+func (s *Storage) Patch(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	previous, err := s.items.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := s.items.Patch(id, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.append(record{Op: opUpdate, Id: id, Package: patched}); err != nil {
+		s.items.Update(id, previous)
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete removes a Package item from the storage by ID.
+func (s *Storage) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.items.Get(id); err != nil {
+		return err
+	}
+	if err := s.append(record{Op: opDelete, Id: id}); err != nil {
+		return err
+	}
+	return s.items.Delete(id)
+}
+
+// AdjustStock adds the deltas to the stock of the packages, all or none, see
+// inmemory.Storage.AdjustStock.
+func (s *Storage) AdjustStock(deltas map[string]int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.items.AdjustStock(deltas); err != nil {
+		return err
+	}
+	if err := s.append(record{Op: opAdjustStock, Deltas: deltas}); err != nil {
+		undo := make(map[string]int, len(deltas))
+		for id, delta := range deltas {
+			undo[id] = -delta
+		}
+		s.items.AdjustStock(undo)
+		return err
+	}
+	return nil
+}
+
+// ReturnStock adds the quantities to the stock of the packages that still
+// exist, logged as a stock adjustment of those packages.
+func (s *Storage) ReturnStock(quantities map[string]int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	deltas := make(map[string]int, len(quantities))
+	for id, quantity := range quantities {
+		if _, err := s.items.Get(id); err == nil {
+			deltas[id] = quantity
+		}
+	}
+	if err := s.items.AdjustStock(deltas); err != nil {
+		return err
+	}
+	if err := s.append(record{Op: opAdjustStock, Deltas: deltas}); err != nil {
+		undo := make(map[string]int, len(deltas))
+		for id, delta := range deltas {
+			undo[id] = -delta
+		}
+		s.items.AdjustStock(undo)
+		return err
+	}
+	return nil
+}
+
+// GetAllPackages fetch all packages
+func (s *Storage) GetAllPackages() ([]*domain.Package, error) {
+	return s.items.GetAllPackages()
+}
+
+// ReplaceAll replaces the Package items of the storage in one record.
+func (s *Storage) ReplaceAll(items []*domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	previous, err := s.items.GetAllPackages()
+	if err != nil {
+		return err
+	}
+	if err = s.items.ReplaceAll(items); err != nil {
+		return err
+	}
+	if err = s.append(record{Op: opReplaceAll, Packages: items}); err != nil {
+		s.items.ReplaceAll(previous)
+		return err
+	}
+	return nil
+}
+
+// append writes the record to the log and syncs it. A record that cannot be
+// written whole is cut from the log again, so it is not replayed later.
+func (s *Storage) append(r record) error {
+	r.Seq = s.seq + 1
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err = s.log.Write(line); err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		s.log.Truncate(s.size)
+		s.log.Seek(s.size, io.SeekStart)
+		return fmt.Errorf("failed to write log record: %w", err)
+	}
+	s.size += int64(len(line))
+	s.seq = r.Seq
+
+	if s.pending++; s.pending >= s.snapshotEvery {
+		// the write is durable in the log already, so a failed compaction
+		// is retried after the next write rather than reported
+		if err := s.compact(); err == nil {
+			s.pending = 0
+		}
+	}
+	return nil
+}
+
+// compact writes the packages to a new snapshot, which atomically replaces
+// the previous one, then empties the log.
+func (s *Storage) compact() error {
+	packages, err := s.items.GetAllPackages()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot{Seq: s.seq, Packages: packages})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err = writeFileSync(filepath.Join(s.dir, snapshotName), data); err != nil {
+		return err
+	}
+	if err = s.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	if _, err = s.log.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	s.size = 0
+	return s.log.Sync()
+}
+
+func (s *Storage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var loaded snapshot
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	for _, item := range loaded.Packages {
+		if err = s.items.Create(item); err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+	}
+	s.seq = loaded.Seq
+	return nil
+}
+
+// replayLog applies the records of the log newer than the snapshot and opens
+// it for appending.
+func (s *Storage) replayLog() error {
+	log, err := os.OpenFile(filepath.Join(s.dir, logName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	reader := bufio.NewReader(log)
+	var size int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a record without its newline was torn by a crash
+			break
+		}
+		if err != nil {
+			log.Close()
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		var r record
+		if err = json.Unmarshal(bytes.TrimSpace(line), &r); err != nil {
+			log.Close()
+			return fmt.Errorf("failed to decode log record at offset %d: %w", size, err)
+		}
+		if r.Seq > s.seq {
+			if err = s.apply(r); err != nil {
+				log.Close()
+				return fmt.Errorf("failed to replay log record %d: %w", r.Seq, err)
+			}
+			s.seq = r.Seq
+			s.pending++
+		}
+		size += int64(len(line))
+	}
+	if err = log.Truncate(size); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	if _, err = log.Seek(size, io.SeekStart); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	s.log, s.size = log, size
+	return nil
+}
+
+func (s *Storage) apply(r record) error {
+	switch r.Op {
+	case opCreate:
+		return s.items.Create(r.Package)
+	case opCreateAll:
+		return s.items.CreateAll(r.Packages)
+	case opUpdate:
+		return s.items.Update(r.Id, r.Package)
+	case opDelete:
+		return s.items.Delete(r.Id)
+	case opAdjustStock:
+		return s.items.AdjustStock(r.Deltas)
+	case opReplaceAll:
+		return s.items.ReplaceAll(r.Packages)
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+}
+
+// writeFileSync replaces the file with data through a synced temporary file,
+// so a crash leaves either the old or the new content.
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	// the rename is only durable once the directory is synced
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to sync storage directory: %w", err)
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,145 @@
+package file
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github/ahmedghazey/packaging/internal/domain"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 250, Stock: 5}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 500, Stock: 1}
+	p3 := &domain.Package{Id: uuid.NewString(), Sku: "bolts", Size: 100}
+
+	// writes replays the same writes against every storage
+	writes := func(t *testing.T, s *Storage) {
+		require.NoError(t, s.Create(p1))
+		require.NoError(t, s.Create(p2))
+		require.NoError(t, s.Create(p3))
+		assert.Error(t, s.Create(&domain.Package{Id: uuid.NewString(), Size: 250}), "duplicate sizes are rejected")
+		require.NoError(t, s.Update(p2.Id, &domain.Package{Id: p2.Id, Size: 1000, Stock: 1}))
+		assert.ErrorIs(t, s.Update(p2.Id, &domain.Package{Id: p2.Id, Size: 250}), domain.ErrDuplicatePackageSize)
+		assert.ErrorIs(t, s.Update(uuid.NewString(), &domain.Package{Size: 10}), domain.ErrPackageNotFound)
+		require.NoError(t, s.Delete(p3.Id))
+		assert.ErrorIs(t, s.Delete(p3.Id), domain.ErrPackageNotFound)
+		require.NoError(t, s.AdjustStock(map[string]int{p1.Id: -3, p2.Id: -1}))
+		assert.ErrorIs(t, s.AdjustStock(map[string]int{p1.Id: -3}), domain.ErrInsufficientStock)
+		require.NoError(t, s.ReturnStock(map[string]int{p1.Id: 1, p3.Id: 1}), "deleted packages are skipped")
+		require.NoError(t, s.AdjustStock(map[string]int{p1.Id: -1}))
+		weight := 3.0
+		patched, err := s.Patch(p1.Id, domain.PackagePatch{Weight: &weight})
+		require.NoError(t, err)
+		assert.Equal(t, 2, patched.Stock, "the adjusted stock is kept")
+	}
+	expected := []*domain.Package{{Id: p1.Id, Size: 250, Stock: 2, Weight: 3}, {Id: p2.Id, Size: 1000}}
+
+	testCases := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "Log only", snapshotEvery: 100},
+		{name: "Snapshot and log", snapshotEvery: 3},
+		{name: "Snapshot only", snapshotEvery: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := NewStorage(dir, tc.snapshotEvery)
+			require.NoError(t, err)
+			writes(t, s)
+			assert.Equal(t, expected, allPackages(t, s))
+			require.NoError(t, s.Close())
+
+			reopened, err := NewStorage(dir, tc.snapshotEvery)
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			assert.Equal(t, expected, allPackages(t, reopened))
+			require.NoError(t, reopened.Create(&domain.Package{Id: uuid.NewString(), Size: 2000}))
+			assert.Len(t, allPackages(t, reopened), 3)
+		})
+	}
+
+	t.Run("Batches", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		require.NoError(t, s.CreateAll([]*domain.Package{p1, p2}))
+		err = s.CreateAll([]*domain.Package{p3, {Id: uuid.NewString(), Size: 250}})
+		assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+		require.NoError(t, s.ReplaceAll([]*domain.Package{p2, p3}))
+		assert.Error(t, s.ReplaceAll([]*domain.Package{p1, p1}))
+		require.NoError(t, s.Close())
+
+		reopened, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		assert.Equal(t, []*domain.Package{p2, p3}, allPackages(t, reopened), "rejected batches are not logged")
+	})
+
+	t.Run("Torn record", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		require.NoError(t, s.Create(p1))
+		require.NoError(t, s.Close())
+		log, err := os.OpenFile(filepath.Join(dir, logName), os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = log.WriteString(`{"seq":2,"op":"create","package":{"ID":`)
+		require.NoError(t, err)
+		require.NoError(t, log.Close())
+
+		reopened, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		assert.Equal(t, []*domain.Package{p1}, allPackages(t, reopened))
+		require.NoError(t, reopened.Create(p2))
+		reopened.Close()
+		again, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		defer again.Close()
+		assert.Equal(t, []*domain.Package{p1, p2}, allPackages(t, again))
+	})
+
+	t.Run("Log kept after snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		require.NoError(t, s.Create(p1))
+		require.NoError(t, s.AdjustStock(map[string]int{p1.Id: -1}))
+		log, err := os.ReadFile(filepath.Join(dir, logName))
+		require.NoError(t, err)
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Close())
+		// a crash between writing the snapshot and emptying the log
+		require.NoError(t, os.WriteFile(filepath.Join(dir, logName), log, 0o644))
+
+		reopened, err := NewStorage(dir, 100)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		assert.Equal(t, []*domain.Package{{Id: p1.Id, Size: 250, Stock: 4}}, allPackages(t, reopened))
+	})
+
+	t.Run("Corrupt log", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, logName), []byte("not a record\n"), 0o644))
+
+		_, err := NewStorage(dir, 100)
+
+		assert.Error(t, err)
+	})
+}
+
+func allPackages(t *testing.T, s *Storage) []*domain.Package {
+	packages, err := s.GetAllPackages()
+	require.NoError(t, err)
+	return packages
+}
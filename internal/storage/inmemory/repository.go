@@ -2,12 +2,16 @@ package inmemory
 
 import (
 	"fmt"
-	"github.com/google/uuid"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/repository"
+	"slices"
 	"sync"
 )
 
+var _ repository.PackageRepository = (*Storage)(nil)
+
 type Storage struct {
-	Items []*Package
+	Items []*domain.Package
 	lock  sync.Mutex
 }
 
@@ -16,73 +20,181 @@ func NewStorage() *Storage {
 	return &Storage{}
 }
 
-// Create adds a new Package item to the storage.
-func (s *Storage) Create(item *Package) error {
+// Create adds a copy of the Package item to the storage.
+func (s *Storage) Create(item *domain.Package) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	for _, existing := range s.Items {
-		if existing.Size == item.Size {
-			return fmt.Errorf("package with size %d already exists", item.Size)
-		}
+	if err := s.checkDuplicate(item, ""); err != nil {
+		return err
 	}
 
-	s.Items = append(s.Items, item)
+	created := *item
+	s.Items = append(s.Items, &created)
 	return nil
 }
 
-// Get retrieves a Package item from the storage by ID.
-func (s *Storage) Get(id uuid.UUID) (*Package, bool) {
+// CreateAll adds copies of the Package items to the storage, all or none.
+func (s *Storage) CreateAll(items []*domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := domain.CheckBatch(s.Items, items); err != nil {
+		return err
+	}
+
+	s.Items = append(s.Items, copies(items)...)
+	return nil
+}
+
+// Get retrieves a copy of a Package item from the storage by ID.
+func (s *Storage) Get(id string) (*domain.Package, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	for _, item := range s.Items {
-		if item.ID == id {
-			return item, true
+		if item.Id == id {
+			found := *item
+			return &found, nil
 		}
 	}
 
-	return nil, false
+	return nil, fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
 }
 
 // Update updates a Package item in the storage by ID.
-func (s *Storage) Update(id uuid.UUID, updatedPackage *Package) bool {
+func (s *Storage) Update(id string, updatedPackage *domain.Package) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	for i, item := range s.Items {
-		if item.ID == id {
-			s.Items[i] = updatedPackage
-			return true
+		if item.Id == id {
+			if err := s.checkDuplicate(updatedPackage, id); err != nil {
+				return err
+			}
+			updated := *updatedPackage
+			updated.Id = id
+			s.Items[i] = &updated
+			return nil
 		}
 	}
 
-	return false
+	return fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+}
+
+// Patch changes the patch's fields of a Package item in the storage by ID and
+// returns a copy of the result.
+func (s *Storage) Patch(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, item := range s.Items {
+		if item.Id == id {
+			patched := *item
+			patch.Apply(&patched)
+			if err := s.checkDuplicate(&patched, id); err != nil {
+				return nil, err
+			}
+			s.Items[i] = &patched
+			result := patched
+			return &result, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
 }
 
 // Delete removes a Package item from the storage by ID.
-func (s *Storage) Delete(id uuid.UUID) bool {
+func (s *Storage) Delete(id string) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	for i, item := range s.Items {
-		if item.ID == id {
+		if item.Id == id {
 			// Remove the item from the slice by slicing it.
 			s.Items = append(s.Items[:i], s.Items[i+1:]...)
-			return true
+			return nil
 		}
 	}
 
-	return false
+	return fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
 }
 
-// GetAllPackages fetch all packages
-func (s *Storage) GetAllPackages() []*Package {
+// AdjustStock adds the deltas to the stock of the packages they are keyed by,
+// all or none: it fails without changes when a package is missing or its
+// stock would drop below zero.
+func (s *Storage) AdjustStock(deltas map[string]int) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	packages := make([]*Package, 0, len(s.Items))
-	for _, item := range s.Items {
-		packages = append(packages, item)
+	updated := make(map[int]*domain.Package, len(deltas))
+	for id, delta := range deltas {
+		i := slices.IndexFunc(s.Items, func(item *domain.Package) bool { return item.Id == id })
+		if i < 0 {
+			return fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+		}
+		item := *s.Items[i]
+		item.Stock += delta
+		if item.Stock < 0 {
+			return fmt.Errorf("%w: %d packs of size %d in stock", domain.ErrInsufficientStock, s.Items[i].Stock, item.Size)
+		}
+		updated[i] = &item
+	}
+	for i, item := range updated {
+		s.Items[i] = item
+	}
+	return nil
+}
+
+// ReturnStock adds the quantities to the stock of the packages they are keyed
+// by, skipping missing packages.
+func (s *Storage) ReturnStock(quantities map[string]int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, quantity := range quantities {
+		i := slices.IndexFunc(s.Items, func(item *domain.Package) bool { return item.Id == id })
+		if i < 0 {
+			continue
+		}
+		item := *s.Items[i]
+		item.Stock += quantity
+		s.Items[i] = &item
 	}
+	return nil
+}
 
-	return packages
+// GetAllPackages fetch copies of all packages
+func (s *Storage) GetAllPackages() ([]*domain.Package, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return copies(s.Items), nil
+}
+
+// ReplaceAll replaces the Package items of the storage with copies of items.
+func (s *Storage) ReplaceAll(items []*domain.Package) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := domain.CheckBatch(nil, items); err != nil {
+		return err
+	}
+
+	s.Items = copies(items)
+	return nil
+}
+
+// checkDuplicate fails when a package other than the one with the given ID
+// has the item's sku and size.
+func (s *Storage) checkDuplicate(item *domain.Package, id string) error {
+	for _, existing := range s.Items {
+		if existing.Id != id && existing.Sku == item.Sku && existing.Size == item.Size {
+			return domain.DuplicateSizeError(item)
+		}
+	}
+	return nil
+}
+
+func copies(items []*domain.Package) []*domain.Package {
+	copied := make([]*domain.Package, 0, len(items))
+	for _, item := range items {
+		item := *item
+		copied = append(copied, &item)
+	}
+	return copied
 }
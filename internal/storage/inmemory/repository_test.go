@@ -3,6 +3,7 @@ package inmemory
 import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
 	"testing"
 )
 
@@ -12,18 +13,28 @@ func TestCreate(t *testing.T) {
 
 	tests := []struct {
 		name     string
-		input    *Package
-		expected []*Package
+		input    *domain.Package
+		expected []*domain.Package
 	}{
 		{
 			name:     "Add single package",
-			input:    &Package{ID: uuid.New(), Size: 10},
-			expected: []*Package{{ID: uuid.New(), Size: 10}},
+			input:    &domain.Package{Id: uuid.NewString(), Size: 10},
+			expected: []*domain.Package{{Id: uuid.NewString(), Size: 10}},
 		},
 		{
 			name:     "Add multiple packages",
-			input:    &Package{ID: uuid.New(), Size: 20},
-			expected: []*Package{{ID: uuid.New(), Size: 10}, {ID: uuid.New(), Size: 20}},
+			input:    &domain.Package{Id: uuid.NewString(), Size: 20},
+			expected: []*domain.Package{{Id: uuid.NewString(), Size: 10}, {Id: uuid.NewString(), Size: 20}},
+		},
+		{
+			name:     "Add duplicate size",
+			input:    &domain.Package{Id: uuid.NewString(), Size: 20},
+			expected: []*domain.Package{{Id: uuid.NewString(), Size: 10}, {Id: uuid.NewString(), Size: 20}},
+		},
+		{
+			name:     "Add same size for another sku",
+			input:    &domain.Package{Id: uuid.NewString(), Sku: "bolts", Size: 20},
+			expected: []*domain.Package{{Id: uuid.NewString(), Size: 10}, {Id: uuid.NewString(), Size: 20}, {Id: uuid.NewString(), Sku: "bolts", Size: 20}},
 		},
 	}
 
@@ -39,74 +50,79 @@ func TestGet(t *testing.T) {
 
 	s := &Storage{}
 
-	p1 := &Package{ID: uuid.New(), Size: 10}
-	p2 := &Package{ID: uuid.New(), Size: 20}
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
 	s.Items = append(s.Items, p1, p2)
 
 	tests := []struct {
-		name         string
-		searchID     uuid.UUID
-		expectedPkg  *Package
-		expectedBool bool
+		name          string
+		searchID      string
+		expectedPkg   *domain.Package
+		expectedError error
 	}{
 		{
-			name:         "Item found",
-			searchID:     p1.ID,
-			expectedPkg:  p1,
-			expectedBool: true,
+			name:        "Item found",
+			searchID:    p1.Id,
+			expectedPkg: p1,
 		},
 		{
-			name:         "Item not found",
-			searchID:     uuid.New(),
-			expectedPkg:  nil,
-			expectedBool: false,
+			name:          "Item not found",
+			searchID:      uuid.NewString(),
+			expectedPkg:   nil,
+			expectedError: domain.ErrPackageNotFound,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result, found := s.Get(test.searchID)
+			result, err := s.Get(test.searchID)
 
 			assert.Equal(t, test.expectedPkg, result, "Returned package does not match expected")
-			assert.Equal(t, test.expectedBool, found, "Found flag does not match expected")
+			assert.ErrorIs(t, err, test.expectedError)
 		})
 	}
 }
 func TestUpdate(t *testing.T) {
 	s := &Storage{}
 
-	p1 := &Package{ID: uuid.New(), Size: 10}
-	p2 := &Package{ID: uuid.New(), Size: 20}
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
 	s.Items = append(s.Items, p1, p2)
 
 	tests := []struct {
-		name            string
-		updateID        uuid.UUID
-		updatedPackage  *Package
-		expectedSuccess bool
-		expectedItems   []*Package
+		name           string
+		updateID       string
+		updatedPackage *domain.Package
+		expectedError  error
+		expectedItems  []*domain.Package
 	}{
 		{
-			name:            "Update existing item",
-			updateID:        p1.ID,
-			updatedPackage:  &Package{ID: p1.ID, Size: 30},
-			expectedSuccess: true,
-			expectedItems:   []*Package{{ID: p1.ID, Size: 30}, {ID: p2.ID, Size: 20}},
+			name:           "Update existing item",
+			updateID:       p1.Id,
+			updatedPackage: &domain.Package{Id: p1.Id, Size: 30},
+			expectedItems:  []*domain.Package{{Id: p1.Id, Size: 30}, {Id: p2.Id, Size: 20}},
+		},
+		{
+			name:           "Update to a duplicate size",
+			updateID:       p1.Id,
+			updatedPackage: &domain.Package{Id: p1.Id, Size: 20},
+			expectedError:  domain.ErrDuplicatePackageSize,
+			expectedItems:  []*domain.Package{{Id: p1.Id, Size: 30}, {Id: p2.Id, Size: 20}},
 		},
 		{
-			name:            "Update non-existing item",
-			updateID:        uuid.New(),
-			updatedPackage:  &Package{ID: uuid.New(), Size: 40},
-			expectedSuccess: false,
-			expectedItems:   []*Package{{ID: p1.ID, Size: 30}, {ID: p2.ID, Size: 20}},
+			name:           "Update non-existing item",
+			updateID:       uuid.NewString(),
+			updatedPackage: &domain.Package{Id: uuid.NewString(), Size: 40},
+			expectedError:  domain.ErrPackageNotFound,
+			expectedItems:  []*domain.Package{{Id: p1.Id, Size: 30}, {Id: p2.Id, Size: 20}},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			success := s.Update(test.updateID, test.updatedPackage)
+			err := s.Update(test.updateID, test.updatedPackage)
 
-			assert.Equal(t, test.expectedSuccess, success, "Update success status does not match expected")
+			assert.ErrorIs(t, err, test.expectedError)
 			assert.Equal(t, test.expectedItems, s.Items, "Storage items do not match expected after update")
 
 		})
@@ -115,35 +131,34 @@ func TestUpdate(t *testing.T) {
 func TestDelete(t *testing.T) {
 	s := &Storage{}
 
-	p1 := &Package{ID: uuid.New(), Size: 10}
-	p2 := &Package{ID: uuid.New(), Size: 20}
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
 	s.Items = append(s.Items, p1, p2)
 
 	tests := []struct {
-		name            string
-		deleteID        uuid.UUID
-		expectedSuccess bool
-		expectedItems   []*Package
+		name          string
+		deleteID      string
+		expectedError error
+		expectedItems []*domain.Package
 	}{
 		{
-			name:            "Delete existing item",
-			deleteID:        p1.ID,
-			expectedSuccess: true,
-			expectedItems:   []*Package{{ID: p2.ID, Size: 20}},
+			name:          "Delete existing item",
+			deleteID:      p1.Id,
+			expectedItems: []*domain.Package{{Id: p2.Id, Size: 20}},
 		},
 		{
-			name:            "Delete non-existing item",
-			deleteID:        uuid.New(),
-			expectedSuccess: false,
-			expectedItems:   []*Package{{ID: p2.ID, Size: 20}},
+			name:          "Delete non-existing item",
+			deleteID:      uuid.NewString(),
+			expectedError: domain.ErrPackageNotFound,
+			expectedItems: []*domain.Package{{Id: p2.Id, Size: 20}},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			success := s.Delete(test.deleteID)
+			err := s.Delete(test.deleteID)
 
-			assert.Equal(t, test.expectedSuccess, success, "Delete success status does not match expected")
+			assert.ErrorIs(t, err, test.expectedError)
 			assert.Equal(t, test.expectedItems, s.Items, "Storage items do not match expected after delete")
 
 		})
@@ -152,11 +167,13 @@ func TestDelete(t *testing.T) {
 func TestGetAllPackages(t *testing.T) {
 	s := &Storage{}
 
-	p1 := &Package{ID: uuid.New(), Size: 10}
-	p2 := &Package{ID: uuid.New(), Size: 20}
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
 	s.Items = append(s.Items, p1, p2)
 
-	packages := s.GetAllPackages()
+	packages, err := s.GetAllPackages()
+
+	assert.NoError(t, err)
 
 	assert.Len(t, packages, len(s.Items), "Number of packages returned by GetAllPackages does not match the storage")
 
@@ -164,3 +181,113 @@ func TestGetAllPackages(t *testing.T) {
 		assert.Equal(t, s.Items[i], item, "Package at index %d does not match the storage", i)
 	}
 }
+func TestAdjustStock(t *testing.T) {
+	s := &Storage{}
+
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10, Stock: 5}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20, Stock: 1}
+	s.Items = append(s.Items, p1, p2)
+
+	tests := []struct {
+		name          string
+		deltas        map[string]int
+		expectedError error
+		expectedItems []*domain.Package
+	}{
+		{
+			name:          "Reserve packs",
+			deltas:        map[string]int{p1.Id: -3, p2.Id: -1},
+			expectedItems: []*domain.Package{{Id: p1.Id, Size: 10, Stock: 2}, {Id: p2.Id, Size: 20, Stock: 0}},
+		},
+		{
+			name:          "Insufficient stock",
+			deltas:        map[string]int{p1.Id: -1, p2.Id: -1},
+			expectedError: domain.ErrInsufficientStock,
+			expectedItems: []*domain.Package{{Id: p1.Id, Size: 10, Stock: 2}, {Id: p2.Id, Size: 20, Stock: 0}},
+		},
+		{
+			name:          "Return packs",
+			deltas:        map[string]int{p2.Id: 1},
+			expectedItems: []*domain.Package{{Id: p1.Id, Size: 10, Stock: 2}, {Id: p2.Id, Size: 20, Stock: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.AdjustStock(test.deltas)
+
+			assert.ErrorIs(t, err, test.expectedError)
+			assert.Equal(t, test.expectedItems, s.Items, "Storage items do not match expected after adjusting stock")
+		})
+	}
+
+	assert.Equal(t, 5, p1.Stock, "Packages handed out must not change")
+}
+func TestReturnStock(t *testing.T) {
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10, Stock: 2}
+	s := &Storage{Items: []*domain.Package{p1}}
+
+	err := s.ReturnStock(map[string]int{p1.Id: 3, uuid.NewString(): 1})
+
+	assert.NoError(t, err, "missing packages are skipped")
+	assert.Equal(t, []*domain.Package{{Id: p1.Id, Size: 10, Stock: 5}}, s.Items)
+	assert.Equal(t, 2, p1.Stock, "Packages handed out must not change")
+}
+func TestCreateAll(t *testing.T) {
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	s := &Storage{Items: []*domain.Package{p1}}
+
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
+	err := s.CreateAll([]*domain.Package{
+		p2,
+		{Id: uuid.NewString(), Size: 10},
+		{Id: uuid.NewString(), Sku: "bolts", Size: 10},
+		{Id: uuid.NewString(), Size: 20},
+	})
+
+	var batchErr *domain.BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+	assert.Len(t, batchErr.Rejected, 2, "every duplicate is reported")
+	assert.Equal(t, 1, batchErr.Rejected[0].Index)
+	assert.Equal(t, 3, batchErr.Rejected[1].Index)
+	assert.Equal(t, []*domain.Package{p1}, s.Items, "a rejected batch adds nothing")
+
+	assert.NoError(t, s.CreateAll([]*domain.Package{p2}))
+	assert.Equal(t, []*domain.Package{p1, p2}, s.Items)
+}
+func TestReplaceAll(t *testing.T) {
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	s := &Storage{Items: []*domain.Package{p1}}
+
+	err := s.ReplaceAll([]*domain.Package{{Id: uuid.NewString(), Size: 20}, {Id: uuid.NewString(), Size: 20}})
+
+	assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+	assert.Equal(t, []*domain.Package{p1}, s.Items, "a rejected catalog replaces nothing")
+
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 10}
+	p3 := &domain.Package{Id: uuid.NewString(), Size: 20}
+	assert.NoError(t, s.ReplaceAll([]*domain.Package{p2, p3}))
+	assert.Equal(t, []*domain.Package{p2, p3}, s.Items)
+	assert.NoError(t, s.ReplaceAll(nil))
+	assert.Empty(t, s.Items)
+}
+func TestPatch(t *testing.T) {
+	p1 := &domain.Package{Id: uuid.NewString(), Size: 10, Stock: 5}
+	p2 := &domain.Package{Id: uuid.NewString(), Size: 20}
+	s := &Storage{Items: []*domain.Package{p1, p2}}
+	size, cost := 10, 1.5
+
+	_, err := s.Patch(p2.Id, domain.PackagePatch{Size: &size})
+	assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+	_, err = s.Patch(uuid.NewString(), domain.PackagePatch{Cost: &cost})
+	assert.ErrorIs(t, err, domain.ErrPackageNotFound)
+
+	assert.NoError(t, s.AdjustStock(map[string]int{p1.Id: -2}))
+	patched, err := s.Patch(p1.Id, domain.PackagePatch{Cost: &cost})
+
+	assert.NoError(t, err)
+	expected := &domain.Package{Id: p1.Id, Size: 10, Stock: 3, Cost: 1.5}
+	assert.Equal(t, expected, patched, "fields left out keep their stored value")
+	assert.Equal(t, []*domain.Package{expected, p2}, s.Items)
+}
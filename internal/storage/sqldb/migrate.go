@@ -0,0 +1,74 @@
+package sqldb
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations holds the schema changes, one file per version named
+// <version>_<description>.sql. Applied files must never be edited; changes go
+// into a new version.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies the migrations newer than the schema version recorded in
+// the database, each in a transaction with the record of its version.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var current int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(strings.TrimPrefix(name, "migrations/"), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s", name)
+		}
+		if version <= current {
+			continue
+		}
+		if err = applyMigration(db, name, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, name string, version int) error {
+	statements, err := migrations.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", name, err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(string(statements)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", name, err)
+	}
+	if _, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", name, err)
+	}
+	return nil
+}
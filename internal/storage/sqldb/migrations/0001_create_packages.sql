@@ -0,0 +1,9 @@
+CREATE TABLE packages (
+    id     TEXT PRIMARY KEY,
+    sku    TEXT NOT NULL DEFAULT '',
+    size   BIGINT NOT NULL CHECK (size > 0),
+    stock  BIGINT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    cost   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+    CONSTRAINT packages_sku_size_key UNIQUE (sku, size)
+);
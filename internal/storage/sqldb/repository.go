@@ -0,0 +1,331 @@
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/repository"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var _ repository.PackageRepository = (*Storage)(nil)
+
+// Drivers the storage runs on: SQLite for development and tests, Postgres in
+// production.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Storage keeps the packages in a SQL database. The schema is migrated when
+// the storage is opened, and the database rejects duplicate sizes of a sku.
+type Storage struct {
+	db *sql.DB
+}
+
+// NewStorage connects to the database with the driver and DSN and applies
+// the pending migrations.
+func NewStorage(driver, dsn string) (*Storage, error) {
+	if driver != DriverSQLite && driver != DriverPostgres {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite serialises writers, and every connection to :memory: opens
+		// a database of its own
+		db.SetMaxOpenConns(1)
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Storage{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Create adds a new Package item to the storage.
+func (s *Storage) Create(item *domain.Package) error {
+	return insertPackage(s.db, item)
+}
+
+// CreateAll adds the Package items to the storage in one transaction, all or
+// none.
+func (s *Storage) CreateAll(items []*domain.Package) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	// the sizes are checked up front to report every duplicate, as Postgres
+	// aborts the transaction at the first
+	existing, err := queryPackages(tx)
+	if err != nil {
+		return err
+	}
+	if err = domain.CheckBatch(existing, items); err != nil {
+		return err
+	}
+	if err = insertPackages(tx, items); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit packages: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a Package item from the storage by ID.
+func (s *Storage) Get(id string) (*domain.Package, error) {
+	row := s.db.QueryRow(`SELECT id, sku, size, stock, cost, weight FROM packages WHERE id = $1`, id)
+	item, err := scanPackage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package: %w", err)
+	}
+	return item, nil
+}
+
+// Update updates a Package item in the storage by ID.
+func (s *Storage) Update(id string, updatedPackage *domain.Package) error {
+	result, err := s.db.Exec(
+		`UPDATE packages SET sku = $1, size = $2, stock = $3, cost = $4, weight = $5 WHERE id = $6`,
+		updatedPackage.Sku, updatedPackage.Size, updatedPackage.Stock, updatedPackage.Cost, updatedPackage.Weight, id,
+	)
+	if err != nil {
+		return writeError(updatedPackage, "update", err)
+	}
+	return found(result, id)
+}
+
+// Patch changes the patch's fields of a Package item in the storage by ID in
+// one statement, so concurrent stock adjustments are kept.
+func (s *Storage) Patch(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	row := s.db.QueryRow(
+		`UPDATE packages SET sku = COALESCE($1, sku), size = COALESCE($2, size), stock = COALESCE($3, stock),
+			cost = COALESCE($4, cost), weight = COALESCE($5, weight)
+		WHERE id = $6 RETURNING id, sku, size, stock, cost, weight`,
+		patch.Sku, patch.Size, patch.Stock, patch.Cost, patch.Weight, id,
+	)
+	item, err := scanPackage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+	}
+	if isUniqueViolation(err) {
+		// the rejected package is described with the stored one patched
+		if item, err = s.Get(id); err != nil {
+			return nil, err
+		}
+		patch.Apply(item)
+		return nil, domain.DuplicateSizeError(item)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update package: %w", err)
+	}
+	return item, nil
+}
+
+// Delete removes a Package item from the storage by ID.
+func (s *Storage) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM packages WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete package: %w", err)
+	}
+	return found(result, id)
+}
+
+// AdjustStock adds the deltas to the stock of the packages they are keyed by
+// in one transaction, failing without changes when a package is missing or
+// its stock would drop below zero.
+func (s *Storage) AdjustStock(deltas map[string]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for id, delta := range deltas {
+		// the stock is checked and changed in one statement, so concurrent
+		// adjustments cannot both take the last packs
+		result, err := tx.Exec(`UPDATE packages SET stock = stock + $1 WHERE id = $2 AND stock + $1 >= 0`, delta, id)
+		if err != nil {
+			return fmt.Errorf("failed to adjust stock: %w", err)
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to adjust stock: %w", err)
+		} else if rows > 0 {
+			continue
+		}
+		var stock, size int
+		err = tx.QueryRow(`SELECT stock, size FROM packages WHERE id = $1`, id).Scan(&stock, &size)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to adjust stock: %w", err)
+		}
+		return fmt.Errorf("%w: %d packs of size %d in stock", domain.ErrInsufficientStock, stock, size)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit stock adjustment: %w", err)
+	}
+	return nil
+}
+
+// ReturnStock adds the quantities to the stock of the packages they are keyed
+// by in one transaction, skipping missing packages.
+func (s *Storage) ReturnStock(quantities map[string]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for id, quantity := range quantities {
+		if _, err := tx.Exec(`UPDATE packages SET stock = stock + $1 WHERE id = $2`, quantity, id); err != nil {
+			return fmt.Errorf("failed to return stock: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit stock return: %w", err)
+	}
+	return nil
+}
+
+// GetAllPackages fetch all packages
+func (s *Storage) GetAllPackages() ([]*domain.Package, error) {
+	return queryPackages(s.db)
+}
+
+// ReplaceAll replaces the Package items of the storage in one transaction.
+func (s *Storage) ReplaceAll(items []*domain.Package) error {
+	if err := domain.CheckBatch(nil, items); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(`DELETE FROM packages`); err != nil {
+		return fmt.Errorf("failed to delete packages: %w", err)
+	}
+	if err = insertPackages(tx, items); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit packages: %w", err)
+	}
+	return nil
+}
+
+// execer runs statements on the database or in a transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// querier runs queries on the database or in a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func insertPackage(db execer, item *domain.Package) error {
+	_, err := db.Exec(
+		`INSERT INTO packages (id, sku, size, stock, cost, weight) VALUES ($1, $2, $3, $4, $5, $6)`,
+		item.Id, item.Sku, item.Size, item.Stock, item.Cost, item.Weight,
+	)
+	if err != nil {
+		return writeError(item, "insert", err)
+	}
+	return nil
+}
+
+// insertPackages inserts the items in order. A size taken by a concurrent
+// write since the batch was checked rejects its package like CheckBatch.
+func insertPackages(db execer, items []*domain.Package) error {
+	for i, item := range items {
+		err := insertPackage(db, item)
+		if errors.Is(err, domain.ErrDuplicatePackageSize) {
+			return &domain.BatchError{Rejected: []domain.RejectedPackage{{Index: i, Err: err}}}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryPackages(db querier) ([]*domain.Package, error) {
+	rows, err := db.Query(`SELECT id, sku, size, stock, cost, weight FROM packages ORDER BY sku, size`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packages: %w", err)
+	}
+	defer rows.Close()
+	packages := make([]*domain.Package, 0)
+	for rows.Next() {
+		item, err := scanPackage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packages: %w", err)
+		}
+		packages = append(packages, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read packages: %w", err)
+	}
+	return packages, nil
+}
+
+func scanPackage(row interface{ Scan(...any) error }) (*domain.Package, error) {
+	var item domain.Package
+	if err := row.Scan(&item.Id, &item.Sku, &item.Size, &item.Stock, &item.Cost, &item.Weight); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// found fails with domain.ErrPackageNotFound when the statement changed no
+// row.
+func found(result sql.Result, id string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+	}
+	return nil
+}
+
+// writeError reports unique violations of the package's sku and size as
+// domain.ErrDuplicatePackageSize.
+func writeError(item *domain.Package, statement string, err error) error {
+	if !isUniqueViolation(err) {
+		return fmt.Errorf("failed to %s package: %w", statement, err)
+	}
+	return domain.DuplicateSizeError(item)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package sqldb
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github/ahmedghazey/packaging/internal/domain"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDatabases lists the databases the tests run against. Postgres runs
+// only when TEST_POSTGRES_DSN points at a database the tests may wipe.
+func testDatabases(t *testing.T) map[string]func(t *testing.T) *Storage {
+	databases := map[string]func(t *testing.T) *Storage{
+		DriverSQLite: func(t *testing.T) *Storage {
+			s, err := NewStorage(DriverSQLite, ":memory:")
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		databases[DriverPostgres] = func(t *testing.T) *Storage {
+			s, err := NewStorage(DriverPostgres, dsn)
+			require.NoError(t, err)
+			_, err = s.db.Exec(`DELETE FROM packages`)
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close() })
+			return s
+		}
+	}
+	return databases
+}
+
+func TestStorage(t *testing.T) {
+	for driver, open := range testDatabases(t) {
+		t.Run(driver, func(t *testing.T) {
+			s := open(t)
+			p1 := &domain.Package{Id: uuid.NewString(), Size: 250, Stock: 5, Cost: 1.5, Weight: 2}
+			p2 := &domain.Package{Id: uuid.NewString(), Size: 500, Stock: 1}
+			p3 := &domain.Package{Id: uuid.NewString(), Sku: "bolts", Size: 250}
+
+			require.NoError(t, s.Create(p1))
+			require.NoError(t, s.Create(p2))
+			require.NoError(t, s.Create(p3), "the same size may exist for another sku")
+			err := s.Create(&domain.Package{Id: uuid.NewString(), Size: 250})
+			assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+
+			found, err := s.Get(p1.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, p1, found)
+			_, err = s.Get(uuid.NewString())
+			assert.ErrorIs(t, err, domain.ErrPackageNotFound)
+
+			require.NoError(t, s.Update(p2.Id, &domain.Package{Id: p2.Id, Size: 1000, Stock: 1}))
+			err = s.Update(p2.Id, &domain.Package{Id: p2.Id, Size: 250})
+			assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize, "the database rejects duplicate sizes")
+			assert.ErrorIs(t, s.Update(uuid.NewString(), &domain.Package{Size: 10}), domain.ErrPackageNotFound)
+			require.NoError(t, s.Delete(p3.Id))
+			assert.ErrorIs(t, s.Delete(p3.Id), domain.ErrPackageNotFound)
+
+			require.NoError(t, s.AdjustStock(map[string]int{p1.Id: -3, p2.Id: -1}))
+			err = s.AdjustStock(map[string]int{p1.Id: 1, p2.Id: -1})
+			assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+			assert.ErrorIs(t, s.AdjustStock(map[string]int{uuid.NewString(): 1}), domain.ErrPackageNotFound)
+			require.NoError(t, s.ReturnStock(map[string]int{p1.Id: 1, p3.Id: 1}), "deleted packages are skipped")
+			require.NoError(t, s.AdjustStock(map[string]int{p1.Id: -1}))
+
+			size, weight := 250, 3.0
+			_, err = s.Patch(p2.Id, domain.PackagePatch{Size: &size})
+			assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+			_, err = s.Patch(uuid.NewString(), domain.PackagePatch{Weight: &weight})
+			assert.ErrorIs(t, err, domain.ErrPackageNotFound)
+			patched, err := s.Patch(p1.Id, domain.PackagePatch{Weight: &weight})
+			require.NoError(t, err)
+			assert.Equal(t, &domain.Package{Id: p1.Id, Size: 250, Stock: 2, Cost: 1.5, Weight: 3}, patched, "the adjusted stock is kept")
+
+			assert.Equal(t, []*domain.Package{
+				{Id: p1.Id, Size: 250, Stock: 2, Cost: 1.5, Weight: 3},
+				{Id: p2.Id, Size: 1000},
+			}, allPackages(t, s), "failed writes change nothing")
+		})
+	}
+}
+
+func TestStorage_Batches(t *testing.T) {
+	for driver, open := range testDatabases(t) {
+		t.Run(driver, func(t *testing.T) {
+			s := open(t)
+			p1 := &domain.Package{Id: uuid.NewString(), Size: 250}
+			p2 := &domain.Package{Id: uuid.NewString(), Size: 500}
+			p3 := &domain.Package{Id: uuid.NewString(), Sku: "bolts", Size: 250}
+			require.NoError(t, s.CreateAll([]*domain.Package{p1, p2}))
+
+			err := s.CreateAll([]*domain.Package{p3, {Id: uuid.NewString(), Size: 250}, {Id: uuid.NewString(), Size: 500}})
+			var batchErr *domain.BatchError
+			require.ErrorAs(t, err, &batchErr)
+			assert.Len(t, batchErr.Rejected, 2, "every duplicate is reported")
+			assert.Equal(t, []*domain.Package{p1, p2}, allPackages(t, s), "a rejected batch adds nothing")
+
+			err = s.ReplaceAll([]*domain.Package{p3, {Id: uuid.NewString(), Sku: "bolts", Size: 250}})
+			assert.ErrorIs(t, err, domain.ErrDuplicatePackageSize)
+			assert.Equal(t, []*domain.Package{p1, p2}, allPackages(t, s), "a rejected catalog replaces nothing")
+
+			require.NoError(t, s.ReplaceAll([]*domain.Package{p2, p3}))
+			assert.Equal(t, []*domain.Package{p2, p3}, allPackages(t, s))
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "packages.db")
+	s, err := NewStorage(DriverSQLite, dsn)
+	require.NoError(t, err)
+	p := &domain.Package{Id: uuid.NewString(), Size: 250}
+	require.NoError(t, s.Create(p))
+	require.NoError(t, s.Close())
+
+	reopened, err := NewStorage(DriverSQLite, dsn)
+	require.NoError(t, err, "applied migrations are skipped")
+	defer reopened.Close()
+
+	assert.Equal(t, []*domain.Package{p}, allPackages(t, reopened))
+	var version int
+	require.NoError(t, reopened.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, 1, version)
+}
+
+func TestNewStorage_UnsupportedDriver(t *testing.T) {
+	_, err := NewStorage("mysql", "")
+
+	assert.Error(t, err)
+}
+
+func allPackages(t *testing.T, s *Storage) []*domain.Package {
+	packages, err := s.GetAllPackages()
+	require.NoError(t, err)
+	return packages
+}
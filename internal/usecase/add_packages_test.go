@@ -14,9 +14,9 @@ type MockPackageService struct {
 	mock.Mock
 }
 
-func (m *MockPackageService) GetAllPackages() []*domain.Package {
+func (m *MockPackageService) GetAllPackages() ([]*domain.Package, error) {
 	args := m.Called()
-	return args.Get(0).([]*domain.Package)
+	return args.Get(0).([]*domain.Package), args.Error(1)
 }
 
 func (m *MockPackageService) CreatePackage(packages ...*domain.Package) error {
@@ -24,19 +24,44 @@ func (m *MockPackageService) CreatePackage(packages ...*domain.Package) error {
 	return args.Error(0)
 }
 
-func (m *MockPackageService) GetPackage(id string) (*domain.Package, bool) {
+func (m *MockPackageService) GetPackage(id string) (*domain.Package, error) {
 	args := m.Called(id)
-	return args.Get(0).(*domain.Package), args.Bool(1)
+	return args.Get(0).(*domain.Package), args.Error(1)
 }
 
-func (m *MockPackageService) UpdatePackage(id string, updatedPackage *domain.Package) (bool, error) {
+func (m *MockPackageService) UpdatePackage(id string, updatedPackage *domain.Package) error {
 	args := m.Called(id, updatedPackage)
-	return args.Bool(0), args.Error(1)
+	return args.Error(0)
+}
+
+func (m *MockPackageService) PatchPackage(id string, patch domain.PackagePatch) (*domain.Package, error) {
+	args := m.Called(id, patch)
+	return args.Get(0).(*domain.Package), args.Error(1)
 }
 
-func (m *MockPackageService) DeletePackage(id string) bool {
+func (m *MockPackageService) DeletePackage(id string) error {
 	args := m.Called(id)
-	return args.Bool(0)
+	return args.Error(0)
+}
+
+func (m *MockPackageService) ReplaceCatalog(packages ...*domain.Package) error {
+	args := m.Called(packages)
+	return args.Error(0)
+}
+
+func (m *MockPackageService) AdjustStock(deltas map[string]int) error {
+	args := m.Called(deltas)
+	return args.Error(0)
+}
+
+func (m *MockPackageService) ReturnStock(quantities map[string]int) error {
+	args := m.Called(quantities)
+	return args.Error(0)
+}
+
+func (m *MockPackageService) CatalogVersion() uint64 {
+	args := m.Called()
+	return args.Get(0).(uint64)
 }
 
 func TestAddPackages_Execute(t *testing.T) {
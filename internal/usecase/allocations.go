@@ -0,0 +1,275 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultRetention is how long closed allocations and finished
+// recommendations can still be looked up.
+const defaultRetention = time.Hour
+
+// releaseRetry is how long an expired allocation whose packs could not be
+// returned to stock waits before it is released again.
+const releaseRetry = 10 * time.Second
+
+// Allocations reserves stock for orders until they are confirmed, released
+// or their reservation expires.
+type Allocations struct {
+	PackagingService service.PackageService
+	// TTL, when positive, is how long an allocation stays reserved.
+	TTL time.Duration
+	// Retention is how long closed allocations are kept before they are
+	// forgotten.
+	Retention time.Duration
+
+	// lock guards the allocations.
+	lock        sync.Mutex
+	allocations map[string]*allocation
+	// replacing is held exclusively by catalog replacements and shared by
+	// reservations taking their packs, so none is taken while one is checked.
+	replacing sync.RWMutex
+}
+
+type allocation struct {
+	domain.Allocation
+	expiry *time.Timer
+}
+
+func NewAllocations(packagingService service.PackageService, ttl time.Duration) *Allocations {
+	return &Allocations{
+		PackagingService: packagingService,
+		TTL:              ttl,
+		Retention:        defaultRetention,
+		allocations:      make(map[string]*allocation),
+	}
+}
+
+// Reserve plans amount items of sku with the options, within the stock on
+// hand, and takes the plan's packs out of stock, all at once so concurrent
+// reservations never share a pack. The packs are taken from the packages of
+// the same catalog read the plan was made from. Reservations are planned
+// concurrently: when the packs were taken or deleted meanwhile, the order is
+// planned again against the changed catalog until ctx is done.
+func (a *Allocations) Reserve(ctx context.Context, options CalculateOptions, sku string, amount int) (domain.Allocation, error) {
+	if amount <= 0 {
+		return domain.Allocation{}, domain.ErrInvalidAmount
+	}
+	options.UseStock, options.Partial = true, false
+	options.Alternatives, options.Explain, options.Capacity = 0, false, domain.Capacity{}
+	calculatePackages := NewCalculatePackages(a.PackagingService, options)
+
+	for {
+		packages, err := a.PackagingService.GetAllPackages()
+		if err != nil {
+			return domain.Allocation{}, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		catalog := domain.ProductCatalog(packages, sku)
+		result, err := calculatePackages.solve(ctx, catalog, amount)
+		if err != nil {
+			return domain.Allocation{}, err
+		}
+		packs, err := packsOf(catalog, result.Plan)
+		if err != nil {
+			return domain.Allocation{}, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		entry := &allocation{Allocation: domain.Allocation{
+			Id:     uuid.New().String(),
+			Sku:    sku,
+			Amount: amount,
+			Plan:   result.Plan,
+			Packs:  packs,
+			Status: domain.AllocationReserved,
+		}}
+		// packages deleted since the catalog was read fail like packs taken
+		// meanwhile; either way another write changed the catalog, so
+		// planning again makes progress
+		allocation, err := a.reserve(entry)
+		if errors.Is(err, domain.ErrInsufficientStock) || errors.Is(err, domain.ErrPackageNotFound) {
+			if ctx.Err() != nil {
+				return domain.Allocation{}, fmt.Errorf("failed to reserve stock: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return domain.Allocation{}, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		return allocation, nil
+	}
+}
+
+// reserve takes the entry's packs out of stock and keeps it reserved.
+func (a *Allocations) reserve(entry *allocation) (domain.Allocation, error) {
+	a.replacing.RLock()
+	defer a.replacing.RUnlock()
+	taken := make(map[string]int, len(entry.Packs))
+	for id, quantity := range entry.Packs {
+		taken[id] = -quantity
+	}
+	if err := a.PackagingService.AdjustStock(taken); err != nil {
+		return domain.Allocation{}, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.TTL > 0 {
+		entry.ExpiresAt = time.Now().Add(a.TTL)
+		entry.expiry = time.AfterFunc(a.TTL, func() {
+			a.Release(entry.Id)
+		})
+	}
+	a.allocations[entry.Id] = entry
+	return entry.Allocation, nil
+}
+
+// ReplaceCatalog replaces the catalog like service.PackageService does,
+// failing with domain.ErrAllocationsReserved while allocations are reserved:
+// the replacement's stock would not account for their packs, which are
+// returned to stock when they are released.
+func (a *Allocations) ReplaceCatalog(items ...*domain.Package) error {
+	a.replacing.Lock()
+	defer a.replacing.Unlock()
+	a.lock.Lock()
+	reserved := 0
+	for _, entry := range a.allocations {
+		if entry.Status == domain.AllocationReserved {
+			reserved++
+		}
+	}
+	a.lock.Unlock()
+	if reserved > 0 {
+		return fmt.Errorf("failed to replace catalog: %w: %d open", domain.ErrAllocationsReserved, reserved)
+	}
+	return a.PackagingService.ReplaceCatalog(items...)
+}
+
+// Get returns the allocation with the given ID.
+func (a *Allocations) Get(id string) (domain.Allocation, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	entry, err := a.find(id)
+	if err != nil {
+		return domain.Allocation{}, err
+	}
+	return entry.Allocation, nil
+}
+
+// Confirm keeps a reserved allocation's packs out of stock for good. An
+// allocation past its expiry expires instead, failing with
+// domain.ErrAllocationClosed.
+func (a *Allocations) Confirm(id string) (domain.Allocation, error) {
+	return a.close(id, domain.AllocationConfirmed)
+}
+
+// Release returns a reserved allocation's packs to stock. Releasing it past
+// its expiry marks it expired.
+func (a *Allocations) Release(id string) (domain.Allocation, error) {
+	return a.close(id, domain.AllocationReleased)
+}
+
+// ReleaseAll releases every reserved allocation, as allocations are only
+// kept in memory: it returns their packs to stock before a shutdown, so they
+// are not lost with the allocations. It returns how many were released.
+func (a *Allocations) ReleaseAll() (int, error) {
+	a.lock.Lock()
+	reserved := make([]string, 0, len(a.allocations))
+	for id, entry := range a.allocations {
+		if entry.Status == domain.AllocationReserved {
+			reserved = append(reserved, id)
+		}
+	}
+	a.lock.Unlock()
+
+	released := 0
+	var errs []error
+	for _, id := range reserved {
+		// a concurrent close wins, and the allocation no longer holds packs
+		if _, err := a.Release(id); err != nil && !errors.Is(err, domain.ErrAllocationClosed) {
+			errs = append(errs, err)
+			continue
+		}
+		released++
+	}
+	return released, errors.Join(errs...)
+}
+
+// close moves a reserved allocation to status, returning its packs to stock
+// unless it is confirmed. Allocations past their expiry can only expire. The
+// allocation is forgotten once closed for the retention.
+func (a *Allocations) close(id string, status domain.AllocationStatus) (domain.Allocation, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	entry, err := a.find(id)
+	if err != nil {
+		return domain.Allocation{}, err
+	}
+	if entry.Status != domain.AllocationReserved {
+		return entry.Allocation, fmt.Errorf("%w: allocation %s is %s", domain.ErrAllocationClosed, id, entry.Status)
+	}
+	expired := !entry.ExpiresAt.IsZero() && !time.Now().Before(entry.ExpiresAt)
+
+	if status == domain.AllocationConfirmed && !expired {
+		a.closed(entry, status)
+		return entry.Allocation, nil
+	}
+	// packages deleted since the reservation take their packs along
+	if err := a.PackagingService.ReturnStock(entry.Packs); err != nil {
+		// the allocation stays reserved, and once expired it is released
+		// again later
+		if expired && entry.expiry != nil {
+			entry.expiry.Reset(releaseRetry)
+		}
+		return entry.Allocation, fmt.Errorf("failed to release stock: %w", err)
+	}
+	if !expired {
+		a.closed(entry, status)
+		return entry.Allocation, nil
+	}
+	a.closed(entry, domain.AllocationExpired)
+	if status == domain.AllocationConfirmed {
+		return entry.Allocation, fmt.Errorf("%w: allocation %s is %s", domain.ErrAllocationClosed, id, entry.Status)
+	}
+	return entry.Allocation, nil
+}
+
+// closed moves the entry to status and forgets it after the retention.
+func (a *Allocations) closed(entry *allocation, status domain.AllocationStatus) {
+	entry.Status = status
+	if entry.expiry != nil {
+		entry.expiry.Stop()
+	}
+	entry.expiry = time.AfterFunc(a.Retention, func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		delete(a.allocations, entry.Id)
+	})
+}
+
+func (a *Allocations) find(id string) (*allocation, error) {
+	entry, found := a.allocations[id]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", domain.ErrAllocationNotFound, id)
+	}
+	return entry, nil
+}
+
+// packsOf maps the plan's packs to the IDs of the catalog packages of their
+// sizes, failing when the catalog has no package of a planned size.
+func packsOf(catalog []*domain.Package, plan domain.PackingPlan) (map[string]int, error) {
+	packs := make(map[string]int, len(plan.Packages))
+	for _, sizedPackage := range plan.Packages {
+		i := slices.IndexFunc(catalog, func(pkg *domain.Package) bool { return pkg.Size == sizedPackage.Size })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: no package of planned size %d", domain.ErrPackageNotFound, sizedPackage.Size)
+		}
+		packs[catalog[i].Id] = sizedPackage.Quantity
+	}
+	return packs, nil
+}
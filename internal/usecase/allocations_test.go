@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/storage/inmemory"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAllocations(t *testing.T) {
+	stock := func(packagingService service.PackageService) map[int]int {
+		stock := make(map[int]int)
+		packages, _ := packagingService.GetAllPackages()
+		for _, pkg := range packages {
+			stock[pkg.Size] = pkg.Stock
+		}
+		return stock
+	}
+	newPackagingService := func() service.PackageService {
+		packagingService := service.NewService(inmemory.NewStorage())
+		packagingService.CreatePackage(&domain.Package{Size: 10, Stock: 2}, &domain.Package{Size: 4, Stock: 5})
+		return packagingService
+	}
+
+	t.Run("Reserve and confirm", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, time.Minute)
+
+		allocation, err := allocations.Reserve(context.Background(), CalculateOptions{}, "", 18)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AllocationReserved, allocation.Status)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 10, Quantity: 1}, {Size: 4, Quantity: 2}}, allocation.Plan.Packages)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), allocation.ExpiresAt, time.Second)
+		assert.Equal(t, map[int]int{10: 1, 4: 3}, stock(packagingService))
+
+		allocation, err = allocations.Confirm(allocation.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AllocationConfirmed, allocation.Status)
+		assert.Equal(t, map[int]int{10: 1, 4: 3}, stock(packagingService))
+
+		_, err = allocations.Release(allocation.Id)
+
+		assert.ErrorIs(t, err, domain.ErrAllocationClosed)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 0)
+
+		allocation, err := allocations.Reserve(context.Background(), CalculateOptions{}, "", 20)
+		assert.NoError(t, err)
+		assert.Zero(t, allocation.ExpiresAt)
+		assert.Equal(t, map[int]int{10: 0, 4: 5}, stock(packagingService))
+
+		allocation, err = allocations.Release(allocation.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AllocationReleased, allocation.Status)
+		assert.Equal(t, map[int]int{10: 2, 4: 5}, stock(packagingService))
+
+		_, err = allocations.Get("unknown")
+
+		assert.ErrorIs(t, err, domain.ErrAllocationNotFound)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 10*time.Millisecond)
+
+		allocation, err := allocations.Reserve(context.Background(), CalculateOptions{}, "", 8)
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			allocation, _ := allocations.Get(allocation.Id)
+			return allocation.Status == domain.AllocationExpired
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, map[int]int{10: 2, 4: 5}, stock(packagingService))
+
+		_, err = allocations.Confirm(allocation.Id)
+
+		assert.ErrorIs(t, err, domain.ErrAllocationClosed)
+	})
+
+	t.Run("Release all", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 0)
+		confirmed, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 10)
+		allocations.Confirm(confirmed.Id)
+		reserved, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 8)
+
+		released, err := allocations.ReleaseAll()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, released)
+		assert.Equal(t, map[int]int{10: 1, 4: 5}, stock(packagingService), "only the reserved packs return to stock")
+		allocation, _ := allocations.Get(reserved.Id)
+		assert.Equal(t, domain.AllocationReleased, allocation.Status)
+	})
+
+	t.Run("Failed release keeps the allocation reserved", func(t *testing.T) {
+		packagingService := newPackagingService()
+		failing := &failingReturns{PackageService: packagingService, failures: 1}
+		allocations := NewAllocations(failing, 0)
+		allocation, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 8)
+
+		_, err := allocations.Release(allocation.Id)
+
+		assert.ErrorContains(t, err, "failed to release stock")
+		allocation, _ = allocations.Get(allocation.Id)
+		assert.Equal(t, domain.AllocationReserved, allocation.Status)
+
+		allocation, err = allocations.Release(allocation.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AllocationReleased, allocation.Status)
+		assert.Equal(t, map[int]int{10: 2, 4: 5}, stock(packagingService))
+	})
+
+	t.Run("Expired allocation is released again after a failure", func(t *testing.T) {
+		packagingService := newPackagingService()
+		failing := &failingReturns{PackageService: packagingService, failures: 1}
+		allocations := NewAllocations(failing, 10*time.Millisecond)
+		allocation, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 8)
+
+		assert.Eventually(t, func() bool {
+			return failing.Failures() == 0
+		}, time.Second, 5*time.Millisecond)
+		allocation, _ = allocations.Get(allocation.Id)
+		assert.Equal(t, domain.AllocationReserved, allocation.Status, "the packs are still out of stock")
+
+		allocation, err := allocations.Release(allocation.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AllocationExpired, allocation.Status)
+		assert.Equal(t, map[int]int{10: 2, 4: 5}, stock(packagingService))
+	})
+
+	t.Run("Closed allocations are forgotten after the retention", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 0)
+		allocations.Retention = 10 * time.Millisecond
+		allocation, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 8)
+		allocations.Confirm(allocation.Id)
+
+		assert.Eventually(t, func() bool {
+			_, err := allocations.Get(allocation.Id)
+			return errors.Is(err, domain.ErrAllocationNotFound)
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Catalog replaced only without reservations", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 0)
+		allocation, _ := allocations.Reserve(context.Background(), CalculateOptions{}, "", 10)
+
+		err := allocations.ReplaceCatalog(&domain.Package{Size: 10, Stock: 6})
+
+		assert.ErrorIs(t, err, domain.ErrAllocationsReserved)
+		assert.Equal(t, map[int]int{10: 1, 4: 5}, stock(packagingService))
+
+		allocations.Release(allocation.Id)
+		err = allocations.ReplaceCatalog(&domain.Package{Size: 10, Stock: 6})
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]int{10: 6}, stock(packagingService))
+	})
+
+	t.Run("Catalog changed after planning", func(t *testing.T) {
+		packagingService := newPackagingService()
+		packages, _ := packagingService.GetAllPackages()
+		changing := &changingCatalog{PackageService: packagingService, deleteId: packages[0].Id}
+		allocations := NewAllocations(changing, 0)
+
+		allocation, err := allocations.Reserve(context.Background(), CalculateOptions{}, "", 18)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 4, Quantity: 5}}, allocation.Plan.Packages, "the order is planned again")
+		assert.Equal(t, map[string]int{packages[1].Id: 5}, allocation.Packs)
+		assert.Equal(t, map[int]int{4: 0}, stock(packagingService))
+	})
+
+	t.Run("Concurrent reservations never share packs", func(t *testing.T) {
+		packagingService := newPackagingService()
+		allocations := NewAllocations(packagingService, 0)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = allocations.Reserve(context.Background(), CalculateOptions{}, "", 10)
+			}(i)
+		}
+		wg.Wait()
+
+		reserved := 0
+		for _, err := range errs {
+			if err == nil {
+				reserved++
+			} else {
+				assert.ErrorIs(t, err, domain.ErrAmountUnreachable)
+			}
+		}
+		// the two packs of 10, then three of the five packs of 4
+		assert.Equal(t, 3, reserved)
+		assert.Equal(t, map[int]int{10: 0, 4: 2}, stock(packagingService))
+	})
+}
+
+// changingCatalog deletes a package right after the first catalog read, as a
+// concurrent request could.
+type changingCatalog struct {
+	service.PackageService
+	deleteId string
+	once     sync.Once
+}
+
+func (c *changingCatalog) GetAllPackages() ([]*domain.Package, error) {
+	packages, err := c.PackageService.GetAllPackages()
+	c.once.Do(func() { c.PackageService.DeletePackage(c.deleteId) })
+	return packages, err
+}
+
+// failingReturns fails the given number of stock returns.
+type failingReturns struct {
+	service.PackageService
+	lock     sync.Mutex
+	failures int
+}
+
+func (f *failingReturns) ReturnStock(quantities map[string]int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("storage unavailable")
+	}
+	return f.PackageService.ReturnStock(quantities)
+}
+
+func (f *failingReturns) Failures() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.failures
+}
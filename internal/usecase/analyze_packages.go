@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/solver"
+)
+
+type AnalyzePackages struct {
+	PackagingService service.PackageService
+	// Objective decides which sizes are dominated; the zero value follows
+	// the README rules.
+	Objective solver.Objective
+}
+
+func NewAnalyzePackages(packagingService service.PackageService, objective solver.Objective) AnalyzePackages {
+	return AnalyzePackages{
+		PackagingService: packagingService,
+		Objective:        objective,
+	}
+}
+
+// Execute analyses the pack sizes of the product identified by sku, with the
+// overshoot averaged over the amounts from to to.
+func (a AnalyzePackages) Execute(ctx context.Context, sku string, from, to int) (solver.Analysis, error) {
+	packages, err := a.PackagingService.GetAllPackages()
+	if err != nil {
+		return solver.Analysis{}, fmt.Errorf("failed to analyse packages: %w", err)
+	}
+	problem := solver.NewProblem(domain.ProductCatalog(packages, sku), 0)
+	problem.Objective = a.Objective
+	analysis, err := solver.Analyze(ctx, problem, from, to)
+	if err != nil {
+		return solver.Analysis{}, fmt.Errorf("failed to analyse packages: %w", err)
+	}
+	return analysis, nil
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
+	"testing"
+)
+
+func TestAnalyzePackages_Execute(t *testing.T) {
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{
+		{Size: 1000, Cost: 3},
+		{Size: 500, Cost: 2.5},
+		{Size: 250, Cost: 1},
+		{Sku: "bolts", Size: 9},
+		{Sku: "bolts", Size: 6},
+	}, nil)
+
+	t.Run("Default catalog", func(t *testing.T) {
+		objective, _ := solver.ParseObjective(solver.MinimizeCost)
+
+		analysis, err := NewAnalyzePackages(mockPackagingService, objective).Execute(context.Background(), "", 1, 1000)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 250, analysis.Gcd)
+		assert.Nil(t, analysis.Frobenius)
+		assert.Equal(t, []int{500}, analysis.DominatedSizes)
+		assert.Equal(t, 124.5, analysis.AverageOvershoot)
+	})
+
+	t.Run("Product catalog", func(t *testing.T) {
+		analysis, err := NewAnalyzePackages(mockPackagingService, solver.Objective{}).Execute(context.Background(), "bolts", 1, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{9, 6}, analysis.Sizes)
+		assert.Equal(t, 3, analysis.Gcd)
+		assert.Empty(t, analysis.DominatedSizes)
+	})
+
+	t.Run("No package sizes configured", func(t *testing.T) {
+		_, err := NewAnalyzePackages(mockPackagingService, solver.Objective{}).Execute(context.Background(), "nuts", 1, 1)
+
+		assert.ErrorIs(t, err, domain.ErrNoPackageSizes)
+	})
+}
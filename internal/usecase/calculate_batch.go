@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"runtime"
+	"sync"
+)
+
+// BatchOrder is one order of a batch, planned with the catalog of its Sku.
+type BatchOrder struct {
+	OrderId string
+	Sku     string
+	Amount  int
+}
+
+// BatchResult is the outcome of one order of a batch: its plan, or the error
+// that prevented planning it.
+type BatchResult struct {
+	OrderId string
+	Result  CalculateResult
+	Err     error
+}
+
+type CalculateBatch struct {
+	PackagingService service.PackageService
+	Options          CalculateOptions
+}
+
+func NewCalculateBatch(packagingService service.PackageService, options CalculateOptions) CalculateBatch {
+	return CalculateBatch{
+		PackagingService: packagingService,
+		Options:          options,
+	}
+}
+
+// Execute plans every order concurrently against a single snapshot of the
+// catalog, so all orders see the same pack sizes. Results keep the order of
+// the input. Orders not planned before ctx is done carry its error.
+func (c CalculateBatch) Execute(ctx context.Context, orders []BatchOrder) []BatchResult {
+	calculatePackages := NewCalculatePackages(c.PackagingService, c.Options)
+	snapshot := calculatePackages.sharedSnapshot()
+	results := make([]BatchResult, len(orders))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(runtime.GOMAXPROCS(0), len(orders)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i].OrderId = orders[i].OrderId
+				if orders[i].Amount <= 0 {
+					results[i].Err = domain.ErrInvalidAmount
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Result, results[i].Err = calculatePackages.calculate(ctx, snapshot, orders[i].Sku, orders[i].Amount)
+			}
+		}()
+	}
+	for i := range orders {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"github/ahmedghazey/packaging/internal/storage/inmemory"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCalculateBatch_Execute(t *testing.T) {
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{
+		{Size: 5, Stock: 2},
+		{Size: 3, Stock: 1},
+	}, nil)
+
+	t.Run("Plans every order against one snapshot", func(t *testing.T) {
+		orders := make([]BatchOrder, 0, 50)
+		for i := 1; i <= 50; i++ {
+			orders = append(orders, BatchOrder{OrderId: strconv.Itoa(i), Amount: i})
+		}
+		orders = append(orders, BatchOrder{OrderId: "invalid", Amount: 0})
+
+		results := NewCalculateBatch(mockPackagingService, CalculateOptions{}).Execute(context.Background(), orders)
+
+		assert.Len(t, results, len(orders))
+		for i, result := range results[:50] {
+			assert.Equal(t, orders[i].OrderId, result.OrderId)
+			assert.NoError(t, result.Err)
+			assert.GreaterOrEqual(t, result.Result.Plan.Waste, 0)
+		}
+		assert.Equal(t, "invalid", results[50].OrderId)
+		assert.ErrorIs(t, results[50].Err, domain.ErrInvalidAmount)
+		mockPackagingService.AssertNumberOfCalls(t, "GetAllPackages", 1)
+	})
+
+	t.Run("Reports per-order errors", func(t *testing.T) {
+		orders := []BatchOrder{{OrderId: "fits", Amount: 13}, {OrderId: "too big", Amount: 14}}
+
+		results := NewCalculateBatch(mockPackagingService, CalculateOptions{UseStock: true}).Execute(context.Background(), orders)
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 5, Quantity: 2}, {Size: 3, Quantity: 1}}, results[0].Result.Plan.Packages)
+		assert.ErrorIs(t, results[1].Err, domain.ErrAmountUnreachable)
+	})
+	t.Run("Skips the cache when the catalog changes while it is read", func(t *testing.T) {
+		packagingService := service.NewService(inmemory.NewStorage())
+		packagingService.CreatePackage(&domain.Package{Size: 10}, &domain.Package{Size: 4})
+		packages, _ := packagingService.GetAllPackages()
+		options := CalculateOptions{Cache: NewPlanCache(8)}
+		NewCalculatePackages(packagingService, options).Calculate(context.Background(), 10)
+		deleting := &deletingCatalog{PackageService: packagingService, deleteId: packages[0].Id}
+
+		results := NewCalculateBatch(deleting, options).Execute(context.Background(), []BatchOrder{{OrderId: "1", Amount: 10}})
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 4, Quantity: 3}}, results[0].Result.Plan.Packages, "the plan cached before the change is not used")
+	})
+}
+
+// deletingCatalog deletes a package right before the first catalog read, as
+// a concurrent request could.
+type deletingCatalog struct {
+	service.PackageService
+	deleteId string
+	once     sync.Once
+}
+
+func (c *deletingCatalog) GetAllPackages() ([]*domain.Package, error) {
+	c.once.Do(func() { c.PackageService.DeletePackage(c.deleteId) })
+	return c.PackageService.GetAllPackages()
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+)
+
+// OrderLine asks for Amount items of the product identified by Sku.
+type OrderLine struct {
+	Sku    string
+	Amount int
+}
+
+// LinePlan is the plan shipping one line of an order.
+type LinePlan struct {
+	Sku     string
+	Amount  int
+	Plan    domain.PackingPlan
+	Optimal bool
+}
+
+// OrderPlan holds the plan of every line, in the order of the request, and
+// the totals of the whole order. It is optimal when every line is.
+type OrderPlan struct {
+	Lines            []LinePlan
+	Waste            int
+	NumberOfPackages int
+	Cost             float64
+	Optimal          bool
+}
+
+type CalculateOrder struct {
+	PackagingService service.PackageService
+	Options          CalculateOptions
+}
+
+func NewCalculateOrder(packagingService service.PackageService, options CalculateOptions) CalculateOrder {
+	return CalculateOrder{
+		PackagingService: packagingService,
+		Options:          options,
+	}
+}
+
+// Execute plans every line with the pack sizes of its product, all against a
+// single snapshot of the catalog. The order fails as a whole when any line
+// cannot be planned.
+func (c CalculateOrder) Execute(ctx context.Context, lines []OrderLine) (OrderPlan, error) {
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.Amount <= 0 {
+			return OrderPlan{}, fmt.Errorf("invalid line for sku %q: %w", line.Sku, domain.ErrInvalidAmount)
+		}
+		if seen[line.Sku] {
+			return OrderPlan{}, fmt.Errorf("invalid line for sku %q: %w", line.Sku, domain.ErrDuplicateOrderLine)
+		}
+		seen[line.Sku] = true
+	}
+
+	calculatePackages := NewCalculatePackages(c.PackagingService, c.Options)
+	snapshot := calculatePackages.sharedSnapshot()
+	order := OrderPlan{Lines: make([]LinePlan, 0, len(lines)), Optimal: true}
+	for _, line := range lines {
+		result, err := calculatePackages.calculate(ctx, snapshot, line.Sku, line.Amount)
+		if err != nil {
+			return OrderPlan{}, fmt.Errorf("failed to plan sku %q: %w", line.Sku, err)
+		}
+		order.Lines = append(order.Lines, LinePlan{Sku: line.Sku, Amount: line.Amount, Plan: result.Plan, Optimal: result.Optimal})
+		order.Waste += result.Plan.Waste
+		order.NumberOfPackages += result.Plan.NumberOfPackages
+		order.Cost += result.Plan.Cost
+		order.Optimal = order.Optimal && result.Optimal
+	}
+	return order, nil
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"testing"
+)
+
+func TestCalculateOrder_Execute(t *testing.T) {
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{
+		{Sku: "bolts", Size: 100, Cost: 2},
+		{Sku: "nuts", Size: 50, Cost: 1},
+		{Sku: "bolts", Size: 25, Cost: 1},
+		{Size: 10},
+	}, nil)
+	calculateOrder := NewCalculateOrder(mockPackagingService, CalculateOptions{})
+
+	t.Run("Plans every line with its own catalog", func(t *testing.T) {
+		order, err := calculateOrder.Execute(context.Background(), []OrderLine{
+			{Sku: "bolts", Amount: 130},
+			{Sku: "nuts", Amount: 60},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, OrderPlan{
+			Lines: []LinePlan{
+				{Sku: "bolts", Amount: 130, Plan: domain.PackingPlan{
+					Packages:         []*domain.SizedPackage{{Size: 100, Quantity: 1}, {Size: 25, Quantity: 2}},
+					Waste:            20,
+					NumberOfPackages: 3,
+					Cost:             4,
+				}, Optimal: true},
+				{Sku: "nuts", Amount: 60, Plan: domain.PackingPlan{
+					Packages:         []*domain.SizedPackage{{Size: 50, Quantity: 2}},
+					Waste:            40,
+					NumberOfPackages: 2,
+					Cost:             2,
+				}, Optimal: true},
+			},
+			Waste:            60,
+			NumberOfPackages: 5,
+			Cost:             6,
+			Optimal:          true,
+		}, order)
+		mockPackagingService.AssertNumberOfCalls(t, "GetAllPackages", 1)
+	})
+
+	t.Run("Unknown sku", func(t *testing.T) {
+		_, err := calculateOrder.Execute(context.Background(), []OrderLine{{Sku: "washers", Amount: 1}})
+
+		assert.ErrorIs(t, err, domain.ErrNoPackageSizes)
+		assert.EqualError(t, err, `failed to plan sku "washers": failed to calculate packages: no package sizes configured`)
+	})
+
+	t.Run("Invalid lines", func(t *testing.T) {
+		_, err := calculateOrder.Execute(context.Background(), []OrderLine{{Sku: "nuts", Amount: 0}})
+		assert.ErrorIs(t, err, domain.ErrInvalidAmount)
+
+		_, err = calculateOrder.Execute(context.Background(), []OrderLine{{Sku: "nuts", Amount: 1}, {Sku: "nuts", Amount: 2}})
+		assert.ErrorIs(t, err, domain.ErrDuplicateOrderLine)
+	})
+}
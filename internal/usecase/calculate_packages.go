@@ -1,70 +1,303 @@
 package usecase
 
 import (
-	"cmp"
+	"context"
+	"errors"
+	"fmt"
 	"github/ahmedghazey/packaging/internal/domain"
 	"github/ahmedghazey/packaging/internal/service"
-	"slices"
+	"github/ahmedghazey/packaging/internal/solver"
+	"sync"
+	"time"
 )
 
+// CalculateOptions tunes a single calculation.
+type CalculateOptions struct {
+	// Strategy names the registered solver; empty or unknown names fall back
+	// to solver.DefaultStrategy.
+	Strategy string
+	// Alternatives is how many runner-up plans to return besides the best.
+	Alternatives int
+	// UseStock never plans more packs of a size than the catalog has on hand.
+	UseStock bool
+	// Objective ranks the plans; the zero value follows the README rules.
+	Objective solver.Objective
+	// MaxWaste, when positive, rejects plans with more surplus items.
+	MaxWaste int
+	// MaxWastePercent, when positive, rejects plans whose surplus exceeds that
+	// percentage of the order. The stricter of both limits applies.
+	MaxWastePercent float64
+	// ExactOnly rejects every plan shipping surplus items.
+	ExactOnly bool
+	// Partial ships the most items the packs, within stock when UseStock is
+	// set, can hold without exceeding the order, and backorders the rest.
+	Partial bool
+	// Capacity, unless unlimited, splits the plan into the shipments a carrier
+	// accepts.
+	Capacity domain.Capacity
+	// Cache, when set, reuses the results of identical calculations until the
+	// catalog changes.
+	Cache *PlanCache
+	// Budget, when positive, bounds the time the strategy may search before
+	// the calculation falls back to the greedy strategy.
+	Budget time.Duration
+	// Explain adds an Explanation of the winning plan to the result.
+	Explain bool
+}
+
+// CalculateResult is the plan to ship and, when requested, the runner-up
+// plans ranked best first. Plans are priced with the catalog's unit costs.
+// Optimal is false when the budget ran out and the plan comes from the greedy
+// fallback rather than the requested strategy. Backordered is the part of a
+// partial order left unshipped, and Shipments the plan's packs grouped within
+// the carrier's capacity.
+type CalculateResult struct {
+	Plan         domain.PackingPlan
+	Alternatives []domain.PackingPlan
+	Optimal      bool
+	Explanation  *Explanation
+	Backordered  int
+	Shipments    []domain.Shipment
+}
+
+// catalogSnapshot is the catalog a calculation runs against and the version
+// it was read at. The packages are only read once a calculation misses the
+// cache, unless the snapshot is shared by several calculations. Snapshots
+// without a known version skip the cache.
+type catalogSnapshot struct {
+	version  uint64
+	uncached bool
+	packages func() ([]*domain.Package, error)
+}
+
 type CalculatePackages struct {
 	PackagingService service.PackageService
+	Options          CalculateOptions
 }
 
-func NewCalculatePackages(packagingService service.PackageService) CalculatePackages {
+func NewCalculatePackages(packagingService service.PackageService, options CalculateOptions) CalculatePackages {
 	return CalculatePackages{
 		PackagingService: packagingService,
+		Options:          options,
 	}
 }
 
-func (c CalculatePackages) Execute(numberOfItems int) []*domain.SizedPackage {
-	existingPackages := c.PackagingService.GetAllPackages() //return data sorted descending
-	packages := make([]*domain.SizedPackage, 0, len(existingPackages))
-	result := optimizePackages(existingPackages, numberOfItems)
-	slices.SortFunc(result, func(a, b domain.CandidatePackages) int {
-		if n := cmp.Compare(a.Waste(numberOfItems), b.Waste(numberOfItems)); n != 0 {
-			return n
-		}
-		return cmp.Compare(a.NumberOfPackages(), b.NumberOfPackages())
-	})
+func (c CalculatePackages) Execute(ctx context.Context, numberOfItems int) ([]*domain.SizedPackage, error) {
+	result, err := c.Calculate(ctx, numberOfItems)
+	if err != nil {
+		return nil, err
+	}
+	return result.Plan.Packages, nil
+}
+
+func (c CalculatePackages) Calculate(ctx context.Context, numberOfItems int) (CalculateResult, error) {
+	return c.CalculateProduct(ctx, "", numberOfItems)
+}
 
-	for k, v := range result[0].CurrentCombination {
-		packages = append(packages, &domain.SizedPackage{Size: k, Quantity: v})
+// CalculateProduct plans numberOfItems of the product identified by sku with
+// the pack sizes of its own catalog.
+func (c CalculatePackages) CalculateProduct(ctx context.Context, sku string, numberOfItems int) (CalculateResult, error) {
+	return c.calculate(ctx, c.snapshot(), sku, numberOfItems)
+}
+
+func (c CalculatePackages) snapshot() catalogSnapshot {
+	var version uint64
+	if c.Options.Cache != nil {
+		// read the version first, so a change racing with the snapshot can only
+		// file the result under an outdated version
+		version = c.PackagingService.CatalogVersion()
+	}
+	return catalogSnapshot{
+		version:  version,
+		packages: sync.OnceValues(c.PackagingService.GetAllPackages), //return data sorted descending
 	}
-	return packages
 }
 
-func optimizePackages(packages []*domain.Package, order int) []domain.CandidatePackages {
-	var result []domain.CandidatePackages
-	currentCombination := domain.CandidatePackages{make(map[int]int)}
-	memo := make(map[int]domain.CandidatePackages)
-	backtrack(packages, order, currentCombination, &result, memo)
+// sharedSnapshot reads the packages right away, so the calculations sharing
+// the snapshot see the same catalog whether they hit the cache or not. When
+// the catalog changes while it is read, the snapshot skips the cache.
+func (c CalculatePackages) sharedSnapshot() catalogSnapshot {
+	snapshot := c.snapshot()
+	packages, err := snapshot.packages()
+	snapshot.packages = func() ([]*domain.Package, error) { return packages, err }
+	if c.Options.Cache != nil && c.PackagingService.CatalogVersion() != snapshot.version {
+		snapshot.uncached = true
+	}
+	return snapshot
+}
 
-	return result
+// calculate plans numberOfItems of sku against a snapshot of the catalog,
+// reusing a cached result when there is one. Fallback plans are not cached.
+func (c CalculatePackages) calculate(ctx context.Context, snapshot catalogSnapshot, sku string, numberOfItems int) (CalculateResult, error) {
+	if c.Options.Cache == nil || snapshot.uncached {
+		packages, err := snapshot.packages()
+		if err != nil {
+			return CalculateResult{}, fmt.Errorf("failed to calculate packages: %w", err)
+		}
+		return c.solve(ctx, domain.ProductCatalog(packages, sku), numberOfItems)
+	}
+	key := planCacheKey(snapshot.version, sku, numberOfItems, c.Options)
+	if result, found := c.Options.Cache.Get(key); found {
+		return result, nil
+	}
+	packages, err := snapshot.packages()
+	if err != nil {
+		return CalculateResult{}, fmt.Errorf("failed to calculate packages: %w", err)
+	}
+	result, err := c.solve(ctx, domain.ProductCatalog(packages, sku), numberOfItems)
+	if err != nil {
+		return CalculateResult{}, err
+	}
+	if result.Optimal {
+		c.Options.Cache.Add(key, result)
+	}
+	return result, nil
 }
 
-func backtrack(packageSizes []*domain.Package, order int,
-	currentCombination domain.CandidatePackages,
-	result *[]domain.CandidatePackages,
-	memo map[int]domain.CandidatePackages) domain.CandidatePackages {
+func (c CalculatePackages) solve(ctx context.Context, existingPackages []*domain.Package, numberOfItems int) (CalculateResult, error) {
+	problem := solver.NewProblem(existingPackages, numberOfItems)
+	problem.Alternatives = c.Options.Alternatives
+	if c.Options.Explain {
+		problem.Alternatives = max(problem.Alternatives, explainedRunnersUp)
+	}
+	problem.Objective = c.Options.Objective
+	problem.MaxWaste, problem.ExactOnly = c.Options.wasteLimit(numberOfItems)
+	if c.Options.UseStock {
+		problem = problem.WithStock(existingPackages)
+	}
+	if c.Options.Partial {
+		return c.solvePartial(ctx, problem)
+	}
 
-	if order <= 0 {
-		*result = append(*result, currentCombination)
-		return currentCombination
+	candidates, optimal, err := c.search(ctx, problem)
+	if errors.Is(err, domain.ErrAmountUnreachable) && (problem.MaxWaste > 0 || problem.ExactOnly) {
+		err = c.unfulfillable(ctx, problem, err)
 	}
-	if _, ok := memo[order]; ok {
-		return memo[order]
+	if err != nil {
+		return CalculateResult{}, fmt.Errorf("failed to calculate packages: %w", err)
 	}
-	for i := 0; i < len(packageSizes); i++ {
-		packageSize := packageSizes[i].Size
-		newCombination := domain.CandidatePackages{make(map[int]int)}
-		for k, v := range currentCombination.CurrentCombination {
-			newCombination.CurrentCombination[k] = v
+
+	return c.result(ctx, problem, candidates, optimal)
+}
+
+// solvePartial ships the largest quantity not exceeding the order, with the
+// plans the strategy ranks best for exactly that quantity.
+func (c CalculatePackages) solvePartial(ctx context.Context, problem solver.Problem) (CalculateResult, error) {
+	underfill, found, err := solver.Underfill(ctx, problem)
+	if err != nil {
+		return CalculateResult{}, fmt.Errorf("failed to calculate packages: %w", err)
+	}
+	if !found {
+		return CalculateResult{
+			Plan:        domain.PackingPlan{Packages: []*domain.SizedPackage{}},
+			Optimal:     true,
+			Backordered: problem.Amount,
+		}, nil
+	}
+
+	shipped := problem
+	shipped.Amount += underfill.Waste(problem.Amount)
+	shipped.MaxWaste, shipped.ExactOnly = 0, true
+	candidates, optimal, err := c.search(ctx, shipped)
+	if errors.Is(err, domain.ErrAmountUnreachable) {
+		// strategies that are not exact may miss the quantity
+		candidates, optimal, err = []domain.CandidatePackages{underfill}, false, nil
+	}
+	if err != nil {
+		return CalculateResult{}, fmt.Errorf("failed to calculate packages: %w", err)
+	}
+	result, err := c.result(ctx, shipped, candidates, optimal)
+	result.Backordered = problem.Amount - shipped.Amount
+	return result, err
+}
+
+// result prices candidates, ranked best first for the problem, into a
+// CalculateResult and splits the best one into shipments.
+func (c CalculatePackages) result(ctx context.Context, problem solver.Problem, candidates []domain.CandidatePackages, optimal bool) (CalculateResult, error) {
+	result := CalculateResult{Plan: candidates[0].Plan(problem.Amount, problem.Costs), Optimal: optimal}
+	for _, candidate := range candidates[1:min(len(candidates), 1+c.Options.Alternatives)] {
+		result.Alternatives = append(result.Alternatives, candidate.Plan(problem.Amount, problem.Costs))
+	}
+	if c.Options.Explain {
+		result.Explanation = c.explain(ctx, problem, candidates)
+	}
+	if !c.Options.Capacity.Unlimited() {
+		shipments, err := solver.Ship(result.Plan.Packages, problem.Weights, c.Options.Capacity)
+		if err != nil {
+			return CalculateResult{}, fmt.Errorf("failed to split shipments: %w", err)
 		}
+		result.Shipments = shipments
+	}
+	return result, nil
+}
 
-		newCombination.CurrentCombination[packageSize] += 1
+// search runs the configured strategy within the budget, falling back to the
+// greedy strategy when the budget, but not ctx, runs out first.
+func (c CalculatePackages) search(ctx context.Context, problem solver.Problem) ([]domain.CandidatePackages, bool, error) {
+	if c.Options.Budget <= 0 {
+		candidates, err := c.solver().Solve(ctx, problem)
+		return candidates, true, err
+	}
+
+	budgetCtx, cancel := context.WithTimeout(ctx, c.Options.Budget)
+	defer cancel()
+	candidates, err := c.solver().Solve(budgetCtx, problem)
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return candidates, true, err
+	}
+	candidates, err = solver.GreedySolver{}.Solve(ctx, problem)
+	return candidates, false, err
+}
+
+// unfulfillable turns a failure to meet the waste limit into a
+// domain.UnfulfillableError listing the closest plans, or returns err when
+// the order cannot be covered even without the limit.
+func (c CalculatePackages) unfulfillable(ctx context.Context, problem solver.Problem, err error) error {
+	relaxed := problem
+	relaxed.Alternatives = 0
+	relaxed.Objective = solver.Objective{}
+	relaxed.MaxWaste, relaxed.ExactOnly = 0, false
+	over, _, overErr := c.search(ctx, relaxed)
+	if overErr != nil {
+		return err
+	}
+
+	unfulfillable := &domain.UnfulfillableError{
+		Amount: problem.Amount,
+		Over:   over[0].Plan(problem.Amount, problem.Costs),
+	}
+	if !problem.ExactOnly {
+		unfulfillable.MaxWaste = problem.MaxWaste
+	}
+	if under, found, underErr := solver.Underfill(ctx, problem); underErr == nil && found {
+		plan := under.Plan(problem.Amount, problem.Costs)
+		unfulfillable.Under = &plan
+	}
+	return unfulfillable
+}
+
+// wasteLimit resolves the options' limits for an order of numberOfItems into
+// the surplus a plan may ship, no surplus at all when exactOnly is set.
+func (o CalculateOptions) wasteLimit(numberOfItems int) (maxWaste int, exactOnly bool) {
+	maxWaste = o.MaxWaste
+	if o.MaxWastePercent > 0 {
+		percentage := int(float64(numberOfItems) * o.MaxWastePercent / 100)
+		if percentage == 0 {
+			return 0, true
+		}
+		if maxWaste <= 0 || percentage < maxWaste {
+			maxWaste = percentage
+		}
+	}
+	return maxWaste, o.ExactOnly
+}
 
-		memo[order] = backtrack(packageSizes, order-packageSize, newCombination, result, memo)
+// solver resolves the configured strategy, falling back to the default one
+// when it is empty or unknown.
+func (c CalculatePackages) solver() solver.Solver {
+	if s, ok := solver.Get(c.Options.Strategy); ok {
+		return s
 	}
-	return memo[order]
+	s, _ := solver.Get(solver.DefaultStrategy)
+	return s
 }
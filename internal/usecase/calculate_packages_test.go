@@ -1,9 +1,13 @@
 package usecase
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
 	"testing"
+	"time"
 )
 
 func TestCalculatePackages_Execute(t *testing.T) {
@@ -25,10 +29,11 @@ func TestCalculatePackages_Execute(t *testing.T) {
 		}
 
 		// Configure the mock to return the mockPackages data
-		mockPackagingService.On("GetAllPackages").Return(mockPackages)
+		mockPackagingService.On("GetAllPackages").Return(mockPackages, nil)
 
 		// Call the Execute function
-		result := calculatePackages.Execute(numberOfItems)
+		result, err := calculatePackages.Execute(context.Background(), numberOfItems)
+		assert.NoError(t, err)
 
 		// Verify that the result contains the expected packages
 		expectedResult := []*domain.SizedPackage{
@@ -40,8 +45,273 @@ func TestCalculatePackages_Execute(t *testing.T) {
 		mockPackagingService.AssertCalled(t, "GetAllPackages")
 	})
 
-	// Add more test cases as needed to cover different scenarios
+	t.Run("Named strategy", func(t *testing.T) {
+		greedyCalculatePackages := NewCalculatePackages(mockPackagingService, CalculateOptions{Strategy: solver.Greedy})
+
+		result, err := greedyCalculatePackages.Execute(context.Background(), 9)
+		assert.NoError(t, err)
+
+		// greedy takes 5 then 3 and covers the last item with a 2
+		expectedResult := []*domain.SizedPackage{
+			{Size: 5, Quantity: 1},
+			{Size: 3, Quantity: 1},
+			{Size: 2, Quantity: 1},
+		}
+		assert.Equal(t, expectedResult, result)
+	})
+
+	t.Run("Alternative plans", func(t *testing.T) {
+		withAlternatives := NewCalculatePackages(mockPackagingService, CalculateOptions{Alternatives: 2})
+
+		result, err := withAlternatives.Calculate(context.Background(), 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PackingPlan{
+			Packages:         []*domain.SizedPackage{{Size: 5, Quantity: 2}},
+			Waste:            0,
+			NumberOfPackages: 2,
+		}, result.Plan)
+		assert.Equal(t, []domain.PackingPlan{
+			{Packages: []*domain.SizedPackage{{Size: 5, Quantity: 1}, {Size: 3, Quantity: 1}, {Size: 2, Quantity: 1}}, Waste: 0, NumberOfPackages: 3},
+			{Packages: []*domain.SizedPackage{{Size: 3, Quantity: 2}, {Size: 2, Quantity: 2}}, Waste: 0, NumberOfPackages: 4},
+		}, result.Alternatives)
+	})
+
+	t.Run("Limited stock", func(t *testing.T) {
+		stockedPackagingService := new(MockPackageService)
+		stockedPackagingService.On("GetAllPackages").Return([]*domain.Package{
+			{Size: 5, Stock: 1},
+			{Size: 3, Stock: 0},
+			{Size: 2, Stock: 4},
+		}, nil)
+
+		result, err := NewCalculatePackages(stockedPackagingService, CalculateOptions{UseStock: true}).Execute(context.Background(), 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 5, Quantity: 1}, {Size: 2, Quantity: 3}}, result)
+
+		_, err = NewCalculatePackages(stockedPackagingService, CalculateOptions{UseStock: true}).Execute(context.Background(), 14)
+
+		assert.ErrorIs(t, err, domain.ErrAmountUnreachable)
+	})
+
+	t.Run("Minimize cost", func(t *testing.T) {
+		pricedPackagingService := new(MockPackageService)
+		pricedPackagingService.On("GetAllPackages").Return([]*domain.Package{
+			{Size: 5, Cost: 4},
+			{Size: 3, Cost: 1},
+			{Size: 2, Cost: 1},
+		}, nil)
+
+		objective, _ := solver.ParseObjective(solver.MinimizeCost)
+		result, err := NewCalculatePackages(pricedPackagingService, CalculateOptions{
+			Objective: objective,
+			MaxWaste:  1,
+		}).Calculate(context.Background(), 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PackingPlan{
+			Packages:         []*domain.SizedPackage{{Size: 3, Quantity: 2}, {Size: 2, Quantity: 2}},
+			Waste:            0,
+			NumberOfPackages: 4,
+			Cost:             4,
+		}, result.Plan)
+	})
+
+	t.Run("Fewer packs before less waste", func(t *testing.T) {
+		// the rules ship 5+2+2; two packs of 5 overshoot by one item
+		objective, _ := solver.ParseObjective("packs,waste")
+
+		result, err := NewCalculatePackages(mockPackagingService, CalculateOptions{Objective: objective}).Execute(context.Background(), 9)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 5, Quantity: 2}}, result)
+	})
+
+	t.Run("Waste limits", func(t *testing.T) {
+		coarsePackagingService := new(MockPackageService)
+		coarsePackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 10}, {Size: 4}}, nil)
+
+		_, err := NewCalculatePackages(coarsePackagingService, CalculateOptions{ExactOnly: true}).Calculate(context.Background(), 11)
+
+		var unfulfillable *domain.UnfulfillableError
+		assert.ErrorAs(t, err, &unfulfillable)
+		assert.ErrorIs(t, err, domain.ErrAmountUnreachable)
+		assert.Equal(t, &domain.UnfulfillableError{
+			Amount:   11,
+			MaxWaste: 0,
+			Under:    &domain.PackingPlan{Packages: []*domain.SizedPackage{{Size: 10, Quantity: 1}}, Waste: -1, NumberOfPackages: 1},
+			Over:     domain.PackingPlan{Packages: []*domain.SizedPackage{{Size: 4, Quantity: 3}}, Waste: 1, NumberOfPackages: 3},
+		}, unfulfillable)
+
+		tensPackagingService := new(MockPackageService)
+		tensPackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 10}}, nil)
+
+		// 20% of 45 items would allow 9 surplus items, the absolute limit 5
+		result, err := NewCalculatePackages(tensPackagingService, CalculateOptions{MaxWaste: 5, MaxWastePercent: 20}).Calculate(context.Background(), 45)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, result.Plan.Waste)
+
+		// 10% of 25 items allows 2 surplus items, fewer than the absolute limit
+		_, err = NewCalculatePackages(tensPackagingService, CalculateOptions{MaxWaste: 5, MaxWastePercent: 10}).Calculate(context.Background(), 25)
+		assert.ErrorAs(t, err, &unfulfillable)
+		assert.Equal(t, 2, unfulfillable.MaxWaste)
+		assert.Equal(t, -5, unfulfillable.Under.Waste)
+		assert.Equal(t, 5, unfulfillable.Over.Waste)
+	})
+
+	t.Run("Partial fulfilment", func(t *testing.T) {
+		coarsePackagingService := new(MockPackageService)
+		coarsePackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 10, Stock: 1}, {Size: 4, Stock: 5}}, nil)
+
+		result, err := NewCalculatePackages(coarsePackagingService, CalculateOptions{Partial: true}).Calculate(context.Background(), 11)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PackingPlan{
+			Packages:         []*domain.SizedPackage{{Size: 10, Quantity: 1}},
+			Waste:            0,
+			NumberOfPackages: 1,
+		}, result.Plan)
+		assert.Equal(t, 1, result.Backordered)
+		assert.True(t, result.Optimal)
+
+		// one pack of 10 in stock: 10+4*4 is the most that fits 29 items
+		result, err = NewCalculatePackages(coarsePackagingService, CalculateOptions{Partial: true, UseStock: true}).Calculate(context.Background(), 29)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PackingPlan{
+			Packages:         []*domain.SizedPackage{{Size: 10, Quantity: 1}, {Size: 4, Quantity: 4}},
+			Waste:            0,
+			NumberOfPackages: 5,
+		}, result.Plan)
+		assert.Equal(t, 3, result.Backordered)
+
+		result, err = NewCalculatePackages(coarsePackagingService, CalculateOptions{Partial: true}).Calculate(context.Background(), 3)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Plan.Packages)
+		assert.Equal(t, 3, result.Backordered)
+	})
+
+	t.Run("Shipments", func(t *testing.T) {
+		weighedPackagingService := new(MockPackageService)
+		weighedPackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 10, Weight: 4}, {Size: 4, Weight: 2}}, nil)
+
+		result, err := NewCalculatePackages(weighedPackagingService, CalculateOptions{
+			Capacity: domain.Capacity{MaxWeight: 9},
+		}).Calculate(context.Background(), 28)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Shipment{
+			{Packages: []*domain.SizedPackage{{Size: 10, Quantity: 2}}, Count: 1, Items: 20, NumberOfPackages: 2, Weight: 8},
+			{Packages: []*domain.SizedPackage{{Size: 4, Quantity: 2}}, Count: 1, Items: 8, NumberOfPackages: 2, Weight: 4},
+		}, result.Shipments)
+
+		_, err = NewCalculatePackages(weighedPackagingService, CalculateOptions{
+			Capacity: domain.Capacity{MaxWeight: 3},
+		}).Calculate(context.Background(), 28)
+
+		assert.ErrorIs(t, err, domain.ErrPackExceedsCapacity)
+	})
+
+	t.Run("No package sizes configured", func(t *testing.T) {
+		emptyPackagingService := new(MockPackageService)
+		emptyPackagingService.On("GetAllPackages").Return([]*domain.Package{}, nil)
+
+		result, err := NewCalculatePackages(emptyPackagingService, CalculateOptions{}).Execute(context.Background(), 10)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, domain.ErrNoPackageSizes)
+		assert.EqualError(t, err, "failed to calculate packages: no package sizes configured")
+	})
+
+	t.Run("Storage failure", func(t *testing.T) {
+		failingPackagingService := new(MockPackageService)
+		failingPackagingService.On("GetAllPackages").Return([]*domain.Package(nil), errors.New("connection refused"))
+
+		result, err := NewCalculatePackages(failingPackagingService, CalculateOptions{}).Execute(context.Background(), 10)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "failed to calculate packages: connection refused")
+	})
 
 	// Cleanup
 	mockPackagingService.AssertExpectations(t)
 }
+
+func TestCalculatePackages_Cache(t *testing.T) {
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 5}, {Size: 3}}, nil)
+	mockPackagingService.On("CatalogVersion").Return(uint64(1)).Times(3)
+	mockPackagingService.On("CatalogVersion").Return(uint64(2))
+	planCache := NewPlanCache(10)
+	calculatePackages := NewCalculatePackages(mockPackagingService, CalculateOptions{Cache: planCache})
+
+	first, err := calculatePackages.Calculate(context.Background(), 10)
+	assert.NoError(t, err)
+	second, err := calculatePackages.Calculate(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	mockPackagingService.AssertNumberOfCalls(t, "GetAllPackages", 1)
+
+	// other options are a different calculation
+	_, err = NewCalculatePackages(mockPackagingService, CalculateOptions{Cache: planCache, Alternatives: 1}).Calculate(context.Background(), 10)
+	assert.NoError(t, err)
+	mockPackagingService.AssertNumberOfCalls(t, "GetAllPackages", 2)
+
+	// a new catalog version invalidates the cached results
+	_, err = calculatePackages.Calculate(context.Background(), 10)
+	assert.NoError(t, err)
+	mockPackagingService.AssertNumberOfCalls(t, "GetAllPackages", 3)
+
+	assert.Equal(t, uint64(1), planCache.Stats().Hits)
+	assert.Equal(t, uint64(3), planCache.Stats().Misses)
+}
+
+// blockingSolver never finds a plan before its context is done.
+type blockingSolver struct{}
+
+func (blockingSolver) Solve(ctx context.Context, _ solver.Problem) ([]domain.CandidatePackages, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCalculatePackages_Budget(t *testing.T) {
+	solver.Register("blocking", blockingSolver{})
+	t.Cleanup(func() { solver.Unregister("blocking") })
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 5}, {Size: 3}}, nil)
+
+	t.Run("Falls back to greedy when the budget runs out", func(t *testing.T) {
+		calculatePackages := NewCalculatePackages(mockPackagingService, CalculateOptions{
+			Strategy: "blocking",
+			Budget:   10 * time.Millisecond,
+		})
+
+		result, err := calculatePackages.Calculate(context.Background(), 9)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Optimal)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 5, Quantity: 1}, {Size: 3, Quantity: 2}}, result.Plan.Packages)
+	})
+
+	t.Run("Optimal within the budget", func(t *testing.T) {
+		result, err := NewCalculatePackages(mockPackagingService, CalculateOptions{Budget: time.Second}).Calculate(context.Background(), 9)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Optimal)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 3, Quantity: 3}}, result.Plan.Packages)
+	})
+
+	t.Run("Cancelled by the caller", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := NewCalculatePackages(mockPackagingService, CalculateOptions{
+			Strategy: "blocking",
+			Budget:   time.Second,
+		}).Calculate(ctx, 9)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
+	"maps"
+)
+
+// explainedRunnersUp is how many runner-up plans an explanation compares the
+// winning plan with, at least.
+const explainedRunnersUp = 3
+
+// Constraints that can eliminate plans, as reported by explanations.
+const (
+	ConstraintStock    = "stock"
+	ConstraintMaxWaste = "max_waste"
+)
+
+// Explanation tells why the winning plan was chosen: the objective it was
+// ranked under, its overshoot and pack count, the runner-up plans it beat
+// and the best plans that the constraints of the calculation ruled out.
+type Explanation struct {
+	Objective        string
+	Waste            int
+	NumberOfPackages int
+	RunnersUp        []RunnerUp
+	Eliminated       []EliminatedPlan
+}
+
+// RunnerUp is a plan ranked behind the winner, and the criterion the winner
+// beat it on.
+type RunnerUp struct {
+	Plan      domain.PackingPlan
+	DecidedBy solver.Criterion
+}
+
+// EliminatedPlan is the plan that would have won without Constraint.
+type EliminatedPlan struct {
+	Constraint string
+	Plan       domain.PackingPlan
+}
+
+// explain builds the explanation of candidates, ranked best first for the
+// problem. Plans ruled out by constraints are found by solving the problem
+// again without each of them; failures of those solves leave them out.
+func (c CalculatePackages) explain(ctx context.Context, problem solver.Problem, candidates []domain.CandidatePackages) *Explanation {
+	winner := candidates[0]
+	explanation := &Explanation{
+		Objective:        problem.Objective.String(),
+		Waste:            winner.Waste(problem.Amount),
+		NumberOfPackages: winner.NumberOfPackages(),
+	}
+	for _, runnerUp := range candidates[1:] {
+		explanation.RunnersUp = append(explanation.RunnersUp, RunnerUp{
+			Plan:      runnerUp.Plan(problem.Amount, problem.Costs),
+			DecidedBy: problem.Objective.Decide(problem, winner, runnerUp),
+		})
+	}
+
+	if c.Options.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Options.Budget)
+		defer cancel()
+	}
+	relaxations := make(map[string]solver.Problem)
+	if problem.Stock != nil {
+		relaxed := problem
+		relaxed.Stock = nil
+		relaxations[ConstraintStock] = relaxed
+	}
+	if problem.MaxWaste > 0 || problem.ExactOnly {
+		relaxed := problem
+		relaxed.MaxWaste, relaxed.ExactOnly = 0, false
+		relaxations[ConstraintMaxWaste] = relaxed
+	}
+	for _, constraint := range []string{ConstraintStock, ConstraintMaxWaste} {
+		relaxed, ok := relaxations[constraint]
+		if !ok {
+			continue
+		}
+		relaxed.Alternatives = 0
+		best, err := c.solver().Solve(ctx, relaxed)
+		if err != nil || maps.Equal(best[0].CurrentCombination, winner.CurrentCombination) {
+			continue
+		}
+		if problem.Objective.Decide(relaxed, best[0], winner) != "" {
+			explanation.Eliminated = append(explanation.Eliminated, EliminatedPlan{
+				Constraint: constraint,
+				Plan:       best[0].Plan(problem.Amount, problem.Costs),
+			})
+		}
+	}
+	return explanation
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
+	"testing"
+)
+
+func TestCalculatePackages_Explain(t *testing.T) {
+	mockPackagingService := new(MockPackageService)
+	mockPackagingService.On("GetAllPackages").Return([]*domain.Package{
+		{Size: 5, Stock: 1},
+		{Size: 3, Stock: 10},
+		{Size: 2, Stock: 10},
+	}, nil)
+
+	t.Run("Runners-up and eliminated plans", func(t *testing.T) {
+		result, err := NewCalculatePackages(mockPackagingService, CalculateOptions{UseStock: true, Explain: true}).Calculate(context.Background(), 10)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Alternatives)
+		explanation := result.Explanation
+		assert.Equal(t, "waste,packs", explanation.Objective)
+		assert.Equal(t, 0, explanation.Waste)
+		assert.Equal(t, 3, explanation.NumberOfPackages)
+		assert.Len(t, explanation.RunnersUp, 3)
+		assert.Equal(t, RunnerUp{
+			Plan: domain.PackingPlan{
+				Packages:         []*domain.SizedPackage{{Size: 3, Quantity: 2}, {Size: 2, Quantity: 2}},
+				NumberOfPackages: 4,
+			},
+			DecidedBy: solver.Packs,
+		}, explanation.RunnersUp[0])
+		assert.Equal(t, []EliminatedPlan{{
+			Constraint: ConstraintStock,
+			Plan: domain.PackingPlan{
+				Packages:         []*domain.SizedPackage{{Size: 5, Quantity: 2}},
+				NumberOfPackages: 2,
+			},
+		}}, explanation.Eliminated)
+	})
+
+	t.Run("Plan cut by the waste limit", func(t *testing.T) {
+		coarsePackagingService := new(MockPackageService)
+		coarsePackagingService.On("GetAllPackages").Return([]*domain.Package{{Size: 10}, {Size: 3}}, nil)
+		objective, _ := solver.ParseObjective(solver.MinimizePacks)
+
+		result, err := NewCalculatePackages(coarsePackagingService, CalculateOptions{
+			Objective: objective,
+			MaxWaste:  2,
+			Explain:   true,
+		}).Calculate(context.Background(), 7)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.SizedPackage{{Size: 3, Quantity: 3}}, result.Plan.Packages)
+		assert.Equal(t, []EliminatedPlan{{
+			Constraint: ConstraintMaxWaste,
+			Plan: domain.PackingPlan{
+				Packages:         []*domain.SizedPackage{{Size: 10, Quantity: 1}},
+				Waste:            3,
+				NumberOfPackages: 1,
+			},
+		}}, result.Explanation.Eliminated)
+	})
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// maxRecordedAmounts bounds the distinct amounts recorded per sku.
+const maxRecordedAmounts = 10000
+
+// OrderHistory counts the amounts of the orders planned for every sku since
+// the service started, so simulations can replay them. Amounts beyond
+// maxRecordedAmounts distinct ones per sku are not recorded.
+type OrderHistory struct {
+	lock   sync.Mutex
+	orders map[string]map[int]int
+}
+
+func NewOrderHistory() *OrderHistory {
+	return &OrderHistory{orders: make(map[string]map[int]int)}
+}
+
+// Record counts an order of amount items of sku. A nil history records
+// nothing.
+func (h *OrderHistory) Record(sku string, amount int) {
+	if h == nil || amount <= 0 {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	amounts, found := h.orders[sku]
+	if !found {
+		amounts = make(map[int]int)
+		h.orders[sku] = amounts
+	}
+	if _, found := amounts[amount]; found || len(amounts) < maxRecordedAmounts {
+		amounts[amount]++
+	}
+}
+
+// Orders returns the orders recorded for sku by ascending amount.
+func (h *OrderHistory) Orders(sku string) []SimulatedOrder {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	orders := make([]SimulatedOrder, 0, len(h.orders[sku]))
+	for amount, count := range h.orders[sku] {
+		orders = append(orders, SimulatedOrder{Amount: amount, Count: count})
+	}
+	slices.SortFunc(orders, func(a, b SimulatedOrder) int {
+		return cmp.Compare(a.Amount, b.Amount)
+	})
+	return orders
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrderHistory(t *testing.T) {
+	history := NewOrderHistory()
+
+	history.Record("", 500)
+	history.Record("", 250)
+	history.Record("", 500)
+	history.Record("bolts", 12)
+	history.Record("", 0)
+
+	assert.Equal(t, []SimulatedOrder{{Amount: 250, Count: 1}, {Amount: 500, Count: 2}}, history.Orders(""))
+	assert.Equal(t, []SimulatedOrder{{Amount: 12, Count: 1}}, history.Orders("bolts"))
+	assert.Empty(t, history.Orders("nuts"))
+
+	t.Run("Distinct amounts are bounded", func(t *testing.T) {
+		history := NewOrderHistory()
+		for amount := 1; amount <= maxRecordedAmounts+1; amount++ {
+			history.Record("", amount)
+		}
+		history.Record("", 1)
+
+		orders := history.Orders("")
+		assert.Len(t, orders, maxRecordedAmounts)
+		assert.Equal(t, SimulatedOrder{Amount: 1, Count: 2}, orders[0], "recorded amounts are still counted")
+	})
+
+	t.Run("Nil history", func(t *testing.T) {
+		var history *OrderHistory
+
+		history.Record("", 10)
+
+		assert.Empty(t, history.Orders(""))
+	})
+}
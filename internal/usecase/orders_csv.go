@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ReadOrdersCsv reads historical orders from "amount,count" rows, the count
+// defaulting to 1, with an optional header row.
+func ReadOrdersCsv(r io.Reader) ([]SimulatedOrder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	orders := make([]SimulatedOrder, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read orders: %w", err)
+		}
+		if len(record) > 2 {
+			return nil, fmt.Errorf("line %d: expected amount and optional count", line)
+		}
+		amount, err := strconv.Atoi(record[0])
+		if err != nil {
+			if line == 1 {
+				// header
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[0])
+		}
+		order := SimulatedOrder{Amount: amount, Count: 1}
+		if len(record) == 2 {
+			if order.Count, err = strconv.Atoi(record[1]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid count %q", line, record[1])
+			}
+		}
+		if order.Amount <= 0 || order.Count <= 0 {
+			return nil, fmt.Errorf("line %d: amount and count must be positive", line)
+		}
+		orders = append(orders, order)
+	}
+}
@@ -0,0 +1,21 @@
+package usecase
+
+import (
+	"fmt"
+	"github/ahmedghazey/packaging/internal/cache"
+)
+
+// PlanCache keeps calculation results keyed by catalog version, product,
+// amount and options. Cached results are shared and must not be modified.
+type PlanCache = cache.LRU[string, CalculateResult]
+
+// NewPlanCache creates a cache holding up to capacity results.
+func NewPlanCache(capacity int) *PlanCache {
+	return cache.NewLRU[string, CalculateResult](capacity)
+}
+
+func planCacheKey(version uint64, sku string, numberOfItems int, options CalculateOptions) string {
+	return fmt.Sprintf("%d|%q|%d|%s|%d|%t|%v|%d|%g|%t|%t|%v|%t", version, sku, numberOfItems,
+		options.Strategy, options.Alternatives, options.UseStock, options.Objective,
+		options.MaxWaste, options.MaxWastePercent, options.ExactOnly, options.Partial, options.Capacity, options.Explain)
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/solver"
+	"slices"
+	"strconv"
+)
+
+// maxCandidateSizes bounds the sizes a recommendation evaluates: every round
+// of the search plans the whole order history once per candidate.
+const maxCandidateSizes = 100
+
+// Recommendation is a set of pack sizes, sorted descending, and the totals of
+// its plans over the order history. Evaluations counts the size sets planned
+// to find it.
+type Recommendation struct {
+	Sizes       []int
+	Totals      SimulationTotals
+	Evaluations int
+}
+
+// RecommendSizes searches for the set of at most MaxSizes pack sizes that
+// ships an order history with the least overshoot, then the fewest packs.
+// With a positive Step the candidates are the multiples of Step from MinSize
+// to MaxSize; otherwise they are the ordered amounts within those bounds, as
+// a size matching an amount ships it without surplus, the most frequent first
+// when there are too many. Bounds that are not positive do not apply.
+type RecommendSizes struct {
+	MaxSizes int
+	MinSize  int
+	MaxSize  int
+	Step     int
+}
+
+func NewRecommendSizes(maxSizes, minSize, maxSize, step int) RecommendSizes {
+	return RecommendSizes{
+		MaxSizes: maxSizes,
+		MinSize:  minSize,
+		MaxSize:  maxSize,
+		Step:     step,
+	}
+}
+
+// Execute adds, one at a time, the candidate that improves the set the most,
+// then swaps sizes for candidates while that improves it further. Every set
+// is evaluated by planning each order with the exact strategy under the
+// README rules. progress, when set, receives every improvement. When ctx is
+// done first, the best set found so far is returned with ctx's error.
+func (r RecommendSizes) Execute(ctx context.Context, orders []SimulatedOrder, progress func(Recommendation)) (Recommendation, error) {
+	candidates, err := r.validate(orders)
+	if err != nil {
+		return Recommendation{}, err
+	}
+
+	var best Recommendation
+	evaluations := 0
+	improve := func(sizes []int) (bool, error) {
+		evaluations++
+		totals, err := evaluate(ctx, sizes, orders)
+		if err != nil {
+			return false, err
+		}
+		if best.Sizes != nil && compareTotals(totals, best.Totals) >= 0 {
+			return false, nil
+		}
+		best = Recommendation{Sizes: sizes, Totals: totals}
+		if progress != nil {
+			progress(Recommendation{Sizes: sizes, Totals: totals, Evaluations: evaluations})
+		}
+		return true, nil
+	}
+	result := func(err error) (Recommendation, error) {
+		best.Evaluations = evaluations
+		return best, err
+	}
+
+	// greedy: extra sizes never make the optimal plans worse, so growing stops
+	// once no candidate makes them better
+	for grown := true; grown && len(best.Sizes) < r.MaxSizes; {
+		grown = false
+		current := best.Sizes
+		for _, candidate := range candidates {
+			if slices.Contains(current, candidate) {
+				continue
+			}
+			improved, err := improve(withSize(current, candidate))
+			if err != nil {
+				return result(err)
+			}
+			grown = grown || improved
+		}
+	}
+	// local search: swap one size at a time until no swap improves the set
+	for swapped := true; swapped; {
+		swapped = false
+		current := best.Sizes
+		for i := range current {
+			others := slices.Delete(slices.Clone(current), i, i+1)
+			for _, candidate := range candidates {
+				if slices.Contains(current, candidate) {
+					continue
+				}
+				improved, err := improve(withSize(others, candidate))
+				if err != nil {
+					return result(err)
+				}
+				swapped = swapped || improved
+			}
+			if swapped {
+				break
+			}
+		}
+	}
+	return result(nil)
+}
+
+// Validate checks the search can run over the orders before it is started.
+func (r RecommendSizes) Validate(orders []SimulatedOrder) error {
+	_, err := r.validate(orders)
+	return err
+}
+
+func (r RecommendSizes) validate(orders []SimulatedOrder) ([]int, error) {
+	if r.MaxSizes <= 0 {
+		return nil, errors.New("at least one pack size must be recommended")
+	}
+	if len(orders) == 0 {
+		return nil, errors.New("the order history is empty")
+	}
+	return r.candidates(orders)
+}
+
+// candidates lists the sizes the search may pick from.
+func (r RecommendSizes) candidates(orders []SimulatedOrder) ([]int, error) {
+	within := func(size int) bool {
+		return size >= r.MinSize && (r.MaxSize <= 0 || size <= r.MaxSize)
+	}
+	if r.Step > 0 {
+		if r.MaxSize <= 0 {
+			return nil, fmt.Errorf("%w: a step needs a maximum size", domain.ErrTooManyCandidateSizes)
+		}
+		first := max(r.Step, (r.MinSize+r.Step-1)/r.Step*r.Step)
+		if count := (r.MaxSize-first)/r.Step + 1; count > maxCandidateSizes {
+			return nil, fmt.Errorf("%w: %d sizes, at most %d", domain.ErrTooManyCandidateSizes, count, maxCandidateSizes)
+		}
+		candidates := make([]int, 0)
+		for size := first; size <= r.MaxSize; size += r.Step {
+			candidates = append(candidates, size)
+		}
+		if len(candidates) == 0 {
+			return nil, domain.ErrNoPackageSizes
+		}
+		return candidates, nil
+	}
+
+	counts := make(map[int]int)
+	for _, order := range orders {
+		if within(order.Amount) {
+			counts[order.Amount] += order.Count
+		}
+	}
+	candidates := make([]int, 0, len(counts))
+	for amount := range counts {
+		candidates = append(candidates, amount)
+	}
+	slices.SortFunc(candidates, func(a, b int) int {
+		if n := cmp.Compare(counts[b], counts[a]); n != 0 {
+			return n
+		}
+		return cmp.Compare(a, b)
+	})
+	if len(candidates) == 0 {
+		return nil, domain.ErrNoPackageSizes
+	}
+	return candidates[:min(len(candidates), maxCandidateSizes)], nil
+}
+
+// evaluate plans every order with the sizes.
+func evaluate(ctx context.Context, sizes []int, orders []SimulatedOrder) (SimulationTotals, error) {
+	catalog := make(fixedCatalog, 0, len(sizes))
+	for _, size := range sizes {
+		catalog = append(catalog, &domain.Package{Size: size})
+	}
+	batch := make([]BatchOrder, 0, len(orders))
+	for i, order := range orders {
+		batch = append(batch, BatchOrder{OrderId: strconv.Itoa(i), Amount: order.Amount})
+	}
+	results := NewCalculateBatch(catalog, CalculateOptions{Strategy: solver.Exact}).Execute(ctx, batch)
+	if err := ctx.Err(); err != nil {
+		return SimulationTotals{}, err
+	}
+
+	var totals SimulationTotals
+	for i, order := range orders {
+		totals.add(order, results[i])
+	}
+	return totals, nil
+}
+
+// compareTotals ranks fewer unfulfilled orders first, then less overshoot,
+// then fewer packs.
+func compareTotals(x, y SimulationTotals) int {
+	if n := cmp.Compare(x.Unfulfilled, y.Unfulfilled); n != 0 {
+		return n
+	}
+	if n := cmp.Compare(x.Overshoot, y.Overshoot); n != 0 {
+		return n
+	}
+	return cmp.Compare(x.NumberOfPackages, y.NumberOfPackages)
+}
+
+// withSize returns the sizes plus size, sorted descending.
+func withSize(sizes []int, size int) []int {
+	grown := append(slices.Clone(sizes), size)
+	slices.SortFunc(grown, func(a, b int) int {
+		return cmp.Compare(b, a)
+	})
+	return grown
+}
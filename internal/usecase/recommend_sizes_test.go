@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecommendSizes_Execute(t *testing.T) {
+	orders := []SimulatedOrder{{Amount: 250, Count: 5}, {Amount: 500, Count: 2}, {Amount: 1000, Count: 1}}
+
+	t.Run("Sizes from the ordered amounts", func(t *testing.T) {
+		var improvements []Recommendation
+
+		recommendation, err := NewRecommendSizes(2, 0, 0, 0).Execute(context.Background(), orders, func(improvement Recommendation) {
+			improvements = append(improvements, improvement)
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{500, 250}, recommendation.Sizes)
+		assert.Equal(t, SimulationTotals{Orders: 8, Overshoot: 0, NumberOfPackages: 9}, recommendation.Totals)
+		assert.Equal(t, []int{250}, improvements[0].Sizes)
+		assert.Equal(t, recommendation.Sizes, improvements[len(improvements)-1].Sizes)
+		assert.Greater(t, recommendation.Evaluations, len(improvements))
+	})
+
+	t.Run("Sizes on a grid", func(t *testing.T) {
+		recommendation, err := NewRecommendSizes(1, 150, 400, 100).Execute(context.Background(), []SimulatedOrder{{Amount: 700, Count: 3}}, nil)
+
+		assert.NoError(t, err)
+		// 200 and 400 both overshoot by 100, 400 with fewer packs
+		assert.Equal(t, []int{400}, recommendation.Sizes)
+		assert.Equal(t, SimulationTotals{Orders: 3, Overshoot: 300, NumberOfPackages: 6}, recommendation.Totals)
+	})
+
+	t.Run("Too many candidates", func(t *testing.T) {
+		_, err := NewRecommendSizes(1, 1, 1000, 1).Execute(context.Background(), orders, nil)
+
+		assert.ErrorIs(t, err, domain.ErrTooManyCandidateSizes)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		recommendation, err := NewRecommendSizes(2, 0, 0, 0).Execute(ctx, orders, nil)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, recommendation.Sizes)
+	})
+}
+
+func TestReadOrdersCsv(t *testing.T) {
+	orders, err := ReadOrdersCsv(strings.NewReader("amount,count\n250,5\n1000\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SimulatedOrder{{Amount: 250, Count: 5}, {Amount: 1000, Count: 1}}, orders)
+
+	_, err = ReadOrdersCsv(strings.NewReader("250,5\n-3\n"))
+
+	assert.EqualError(t, err, "line 2: amount and count must be positive")
+}
+
+func TestRecommendationJobs(t *testing.T) {
+	orders := []SimulatedOrder{{Amount: 250, Count: 5}, {Amount: 500, Count: 2}, {Amount: 1000, Count: 1}}
+
+	t.Run("Done", func(t *testing.T) {
+		jobs := NewRecommendationJobs(time.Minute)
+
+		job, err := jobs.Start(NewRecommendSizes(2, 0, 0, 0), orders)
+
+		assert.NoError(t, err)
+		assert.Equal(t, JobRunning, job.Status)
+		assert.Eventually(t, func() bool {
+			job, _ = jobs.Get(job.Id)
+			return job.Status != JobRunning
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, JobDone, job.Status)
+		assert.Equal(t, []int{500, 250}, job.Best.Sizes)
+	})
+
+	t.Run("Invalid search", func(t *testing.T) {
+		_, err := NewRecommendationJobs(0).Start(NewRecommendSizes(2, 1, 1000, 1), orders)
+
+		assert.ErrorIs(t, err, domain.ErrTooManyCandidateSizes)
+	})
+
+	t.Run("Too many running", func(t *testing.T) {
+		jobs := NewRecommendationJobs(0)
+		jobs.MaxRunning = 0
+
+		_, err := jobs.Start(NewRecommendSizes(2, 0, 0, 0), orders)
+
+		assert.ErrorIs(t, err, domain.ErrTooManyRecommendations)
+	})
+
+	t.Run("Finished jobs are forgotten after the retention", func(t *testing.T) {
+		jobs := NewRecommendationJobs(0)
+		jobs.Retention = 10 * time.Millisecond
+
+		job, _ := jobs.Start(NewRecommendSizes(2, 0, 0, 0), orders)
+
+		assert.Eventually(t, func() bool {
+			_, err := jobs.Get(job.Id)
+			return errors.Is(err, domain.ErrRecommendationNotFound)
+		}, time.Second, 5*time.Millisecond)
+		_, err := jobs.Start(NewRecommendSizes(2, 0, 0, 0), orders)
+		assert.NoError(t, err, "finished jobs no longer count as running")
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		_, err := NewRecommendationJobs(0).Cancel("unknown")
+
+		assert.ErrorIs(t, err, domain.ErrRecommendationNotFound)
+	})
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github/ahmedghazey/packaging/internal/domain"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// JobStatus is the stage of a RecommendationJob.
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	// JobDone jobs completed their search.
+	JobDone JobStatus = "done"
+	// JobStopped jobs were cancelled or ran out of time; they keep the best
+	// sizes found until then.
+	JobStopped JobStatus = "stopped"
+	JobFailed  JobStatus = "failed"
+)
+
+// RecommendationJob is a size recommendation running in the background. Best
+// is the best set of sizes found so far, nil until the first one is
+// evaluated, and Err why a failed job failed.
+type RecommendationJob struct {
+	Id         string
+	Status     JobStatus
+	Best       *Recommendation
+	Err        error
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// RecommendationJobs runs size recommendations in the background, each for at
+// most Timeout when it is positive, and keeps their outcome for Retention.
+type RecommendationJobs struct {
+	Timeout time.Duration
+	// MaxRunning is how many jobs may run at once; more fail to start.
+	MaxRunning int
+	Retention  time.Duration
+
+	lock    sync.Mutex
+	jobs    map[string]*recommendationJob
+	running int
+}
+
+type recommendationJob struct {
+	RecommendationJob
+	cancel context.CancelFunc
+}
+
+func NewRecommendationJobs(timeout time.Duration) *RecommendationJobs {
+	return &RecommendationJobs{
+		Timeout:    timeout,
+		MaxRunning: runtime.NumCPU(),
+		Retention:  defaultRetention,
+		jobs:       make(map[string]*recommendationJob),
+	}
+}
+
+// Start runs the recommendation over the orders in the background, once they
+// pass its validation. It fails with domain.ErrTooManyRecommendations while
+// MaxRunning jobs are running.
+func (j *RecommendationJobs) Start(recommend RecommendSizes, orders []SimulatedOrder) (RecommendationJob, error) {
+	if err := recommend.Validate(orders); err != nil {
+		return RecommendationJob{}, err
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.running >= j.MaxRunning {
+		return RecommendationJob{}, fmt.Errorf("%w: %d are running", domain.ErrTooManyRecommendations, j.running)
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if j.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), j.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	job := &recommendationJob{
+		RecommendationJob: RecommendationJob{Id: uuid.New().String(), Status: JobRunning, StartedAt: time.Now()},
+		cancel:            cancel,
+	}
+	j.jobs[job.Id] = job
+	j.running++
+
+	go func() {
+		defer cancel()
+		recommendation, err := recommend.Execute(ctx, orders, func(best Recommendation) {
+			j.lock.Lock()
+			defer j.lock.Unlock()
+			job.Best = &best
+		})
+
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		j.running--
+		// finished jobs are forgotten after the retention
+		time.AfterFunc(j.Retention, func() {
+			j.lock.Lock()
+			defer j.lock.Unlock()
+			delete(j.jobs, job.Id)
+		})
+		job.FinishedAt = time.Now()
+		switch {
+		case err == nil:
+			job.Status, job.Best = JobDone, &recommendation
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			job.Status = JobStopped
+			if recommendation.Sizes != nil {
+				job.Best = &recommendation
+			}
+		default:
+			job.Status, job.Err = JobFailed, err
+		}
+	}()
+	return job.RecommendationJob, nil
+}
+
+// Get returns the job with the given ID.
+func (j *RecommendationJobs) Get(id string) (RecommendationJob, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	job, found := j.jobs[id]
+	if !found {
+		return RecommendationJob{}, fmt.Errorf("%w: %s", domain.ErrRecommendationNotFound, id)
+	}
+	return job.RecommendationJob, nil
+}
+
+// Cancel stops a running job shortly after, keeping the best sizes found so
+// far. Finished jobs are left as they are.
+func (j *RecommendationJobs) Cancel(id string) (RecommendationJob, error) {
+	j.lock.Lock()
+	job, found := j.jobs[id]
+	j.lock.Unlock()
+	if !found {
+		return RecommendationJob{}, fmt.Errorf("%w: %s", domain.ErrRecommendationNotFound, id)
+	}
+	job.cancel()
+	return j.Get(id)
+}
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github/ahmedghazey/packaging/internal/domain"
+	"github/ahmedghazey/packaging/internal/service"
+	"strconv"
+)
+
+// errReadOnlyCatalog is returned when a simulation tries to change its
+// catalog.
+var errReadOnlyCatalog = errors.New("simulated catalogs are read-only")
+
+// SimulatedOrder is an order amount and how many times it was placed.
+type SimulatedOrder struct {
+	Amount int
+	Count  int
+}
+
+// SimulationTotals sums the plans of one catalog over every order, each
+// counted as often as it was placed. Unfulfilled counts the orders the
+// catalog could not plan, which the other totals leave out.
+type SimulationTotals struct {
+	Orders           int
+	Overshoot        int
+	NumberOfPackages int
+	Cost             float64
+	Unfulfilled      int
+}
+
+// OrderComparison is the plan of both catalogs for one order. A nil plan
+// comes with the error that prevented it; the deltas, candidate minus
+// baseline, are only set when both plans exist.
+type OrderComparison struct {
+	Amount       int
+	Count        int
+	Baseline     *domain.PackingPlan
+	Candidate    *domain.PackingPlan
+	BaselineErr  error
+	CandidateErr error
+	WasteDelta   int
+	PacksDelta   int
+	CostDelta    float64
+}
+
+// SimulationReport compares two catalogs over the same orders.
+type SimulationReport struct {
+	Baseline  SimulationTotals
+	Candidate SimulationTotals
+	Orders    []OrderComparison
+}
+
+type SimulateCatalog struct {
+	Options CalculateOptions
+}
+
+func NewSimulateCatalog(options CalculateOptions) SimulateCatalog {
+	return SimulateCatalog{
+		Options: options,
+	}
+}
+
+// Execute plans every order with the baseline and the candidate catalog, as
+// CalculatePackages would for the live one, which is left untouched. The skus
+// of the catalogs' packages are ignored. It only fails when ctx is done
+// before every order is planned.
+func (s SimulateCatalog) Execute(ctx context.Context, baseline, candidate []*domain.Package, orders []SimulatedOrder) (SimulationReport, error) {
+	options := s.Options
+	// the simulated catalogs share no version with the live one
+	options.Cache, options.Alternatives, options.Explain = nil, 0, false
+	batch := make([]BatchOrder, 0, len(orders))
+	for i, order := range orders {
+		batch = append(batch, BatchOrder{OrderId: strconv.Itoa(i), Amount: order.Amount})
+	}
+	baselineResults := NewCalculateBatch(newFixedCatalog(baseline), options).Execute(ctx, batch)
+	candidateResults := NewCalculateBatch(newFixedCatalog(candidate), options).Execute(ctx, batch)
+	if err := ctx.Err(); err != nil {
+		return SimulationReport{}, err
+	}
+
+	report := SimulationReport{Orders: make([]OrderComparison, 0, len(orders))}
+	for i, order := range orders {
+		comparison := OrderComparison{Amount: order.Amount, Count: order.Count}
+		comparison.Baseline, comparison.BaselineErr = report.Baseline.add(order, baselineResults[i])
+		comparison.Candidate, comparison.CandidateErr = report.Candidate.add(order, candidateResults[i])
+		if comparison.Baseline != nil && comparison.Candidate != nil {
+			comparison.WasteDelta = comparison.Candidate.Waste - comparison.Baseline.Waste
+			comparison.PacksDelta = comparison.Candidate.NumberOfPackages - comparison.Baseline.NumberOfPackages
+			comparison.CostDelta = comparison.Candidate.Cost - comparison.Baseline.Cost
+		}
+		report.Orders = append(report.Orders, comparison)
+	}
+	return report, nil
+}
+
+// add counts the result of an order in the totals and returns its plan.
+func (t *SimulationTotals) add(order SimulatedOrder, result BatchResult) (*domain.PackingPlan, error) {
+	t.Orders += order.Count
+	if result.Err != nil {
+		t.Unfulfilled += order.Count
+		return nil, result.Err
+	}
+	plan := result.Result.Plan
+	t.Overshoot += order.Count * plan.Waste
+	t.NumberOfPackages += order.Count * plan.NumberOfPackages
+	t.Cost += float64(order.Count) * plan.Cost
+	return &plan, nil
+}
+
+// fixedCatalog serves a simulated catalog to the calculation usecases.
+type fixedCatalog []*domain.Package
+
+var _ service.PackageService = fixedCatalog(nil)
+
+// newFixedCatalog copies packages into the default catalog.
+func newFixedCatalog(packages []*domain.Package) fixedCatalog {
+	catalog := make(fixedCatalog, 0, len(packages))
+	for _, pkg := range packages {
+		pkg := *pkg
+		pkg.Sku = ""
+		catalog = append(catalog, &pkg)
+	}
+	return catalog
+}
+
+func (c fixedCatalog) CreatePackage(...*domain.Package) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) GetPackage(id string) (*domain.Package, error) {
+	for _, pkg := range c {
+		if pkg.Id == id {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", domain.ErrPackageNotFound, id)
+}
+
+func (c fixedCatalog) UpdatePackage(string, *domain.Package) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) PatchPackage(string, domain.PackagePatch) (*domain.Package, error) {
+	return nil, errReadOnlyCatalog
+}
+
+func (c fixedCatalog) DeletePackage(string) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) GetAllPackages() ([]*domain.Package, error) {
+	return c, nil
+}
+
+func (c fixedCatalog) ReplaceCatalog(...*domain.Package) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) AdjustStock(map[string]int) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) ReturnStock(map[string]int) error {
+	return errReadOnlyCatalog
+}
+
+func (c fixedCatalog) CatalogVersion() uint64 {
+	return 0
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github/ahmedghazey/packaging/internal/domain"
+	"testing"
+)
+
+func TestSimulateCatalog_Execute(t *testing.T) {
+	baseline := []*domain.Package{{Sku: "bolts", Size: 500}, {Sku: "bolts", Size: 250}}
+	candidate := []*domain.Package{{Size: 500, Cost: 2}, {Size: 300, Cost: 1}}
+	orders := []SimulatedOrder{{Amount: 250, Count: 3}, {Amount: 600, Count: 1}, {Amount: 1000, Count: 2}}
+
+	t.Run("Compares both catalogs", func(t *testing.T) {
+		report, err := NewSimulateCatalog(CalculateOptions{}).Execute(context.Background(), baseline, candidate, orders)
+
+		assert.NoError(t, err)
+		assert.Equal(t, SimulationTotals{Orders: 6, Overshoot: 150, NumberOfPackages: 9}, report.Baseline)
+		assert.Equal(t, SimulationTotals{Orders: 6, Overshoot: 150, NumberOfPackages: 9, Cost: 13}, report.Candidate)
+		assert.Equal(t, OrderComparison{
+			Amount:     250,
+			Count:      3,
+			Baseline:   &domain.PackingPlan{Packages: []*domain.SizedPackage{{Size: 250, Quantity: 1}}, Waste: 0, NumberOfPackages: 1},
+			Candidate:  &domain.PackingPlan{Packages: []*domain.SizedPackage{{Size: 300, Quantity: 1}}, Waste: 50, NumberOfPackages: 1, Cost: 1},
+			WasteDelta: 50,
+			CostDelta:  1,
+		}, report.Orders[0])
+		assert.Equal(t, -150, report.Orders[1].WasteDelta)
+		assert.Equal(t, 0, report.Orders[2].WasteDelta)
+	})
+
+	t.Run("Unfulfilled orders", func(t *testing.T) {
+		report, err := NewSimulateCatalog(CalculateOptions{MaxWaste: 10}).Execute(context.Background(), baseline, candidate, orders)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Baseline.Unfulfilled)
+		assert.Equal(t, 3, report.Candidate.Unfulfilled)
+		assert.ErrorIs(t, report.Orders[0].CandidateErr, domain.ErrAmountUnreachable)
+		assert.Nil(t, report.Orders[0].Candidate)
+		assert.Zero(t, report.Orders[0].WasteDelta)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := NewSimulateCatalog(CalculateOptions{}).Execute(ctx, baseline, candidate, orders)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}